@@ -0,0 +1,186 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// 关联字段不是列，生成代码的时候要跳过
+var relationTags = []string{"hasOne", "hasMany", "belongsTo", "many2many"}
+
+type model struct {
+	Name   string
+	Fields []string
+}
+
+type file struct {
+	Package string
+	Models  []model
+}
+
+var tpl = template.Must(template.New("toyorm").Parse(`// Code generated by toyorm-gen. DO NOT EDIT.
+
+package {{.Package}}
+
+import "github.com/flycash/toy-orm/lesson"
+{{range .Models}}
+// {{.Name}}Cols 是 {{.Name}} 的列，例如 {{.Name}}Cols.{{index .Fields 0}}.EQ(1)
+var {{.Name}}Cols = struct {
+{{- range .Fields}}
+	{{.}} lesson.Column
+{{- end}}
+}{
+{{- range .Fields}}
+	{{.}}: lesson.C("{{.}}"),
+{{- end}}
+}
+
+func init() {
+	lesson.MustRegister(&{{.Name}}{})
+}
+
+// FieldPtr 实现 lesson.FieldAccessor
+func (m *{{.Name}}) FieldPtr(name string) any {
+	switch name {
+{{- range .Fields}}
+	case "{{.}}":
+		return &m.{{.}}
+{{- end}}
+	}
+	return nil
+}
+
+// FieldValue 实现 lesson.FieldAccessor
+func (m *{{.Name}}) FieldValue(name string) any {
+	switch name {
+{{- range .Fields}}
+	case "{{.}}":
+		return m.{{.}}
+{{- end}}
+	}
+	return nil
+}
+{{end}}`))
+
+// generate 解析 dir 下面的 Go 代码，为 types 生成代码
+func generate(dir string, types []string) ([]byte, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi fs.FileInfo) bool {
+		name := fi.Name()
+		return !strings.HasSuffix(name, "_test.go") && !strings.HasSuffix(name, "_toyorm.go")
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("目录 %s 下面应该有且只有一个包", dir)
+	}
+
+	var pkg *ast.Package
+	for _, p := range pkgs {
+		pkg = p
+	}
+	structs := make(map[string]*ast.TypeSpec, 8)
+	for _, f := range pkg.Files {
+		for _, decl := range f.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if _, ok = ts.Type.(*ast.StructType); ok {
+					structs[ts.Name.Name] = ts
+				}
+			}
+		}
+	}
+
+	res := file{Package: pkg.Name}
+	for _, name := range types {
+		name = strings.TrimSpace(name)
+		ts, ok := structs[name]
+		if !ok {
+			return nil, fmt.Errorf("找不到结构体 %s", name)
+		}
+		if ts.TypeParams != nil {
+			return nil, fmt.Errorf("不支持泛型结构体 %s", name)
+		}
+		m, err := parseModel(ts)
+		if err != nil {
+			return nil, err
+		}
+		res.Models = append(res.Models, m)
+	}
+
+	var buf bytes.Buffer
+	if err = tpl.Execute(&buf, res); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+func parseModel(ts *ast.TypeSpec) (model, error) {
+	m := model{Name: ts.Name.Name}
+	for _, fd := range ts.Type.(*ast.StructType).Fields.List {
+		if isRelation(fd) {
+			continue
+		}
+		if len(fd.Names) == 0 {
+			return m, fmt.Errorf("结构体 %s 不支持组合字段", m.Name)
+		}
+		for _, n := range fd.Names {
+			m.Fields = append(m.Fields, n.Name)
+		}
+	}
+	if len(m.Fields) == 0 {
+		return m, fmt.Errorf("结构体 %s 没有任何列", m.Name)
+	}
+	return m, nil
+}
+
+func isRelation(fd *ast.Field) bool {
+	if fd.Tag == nil {
+		return false
+	}
+	tag, err := strconv.Unquote(fd.Tag.Value)
+	if err != nil {
+		return false
+	}
+	for _, seg := range strings.Split(reflect.StructTag(tag).Get("orm"), ";") {
+		// 和 lesson 一样，many2many=user_roles 和 many2many:user_roles 都是关联
+		key := strings.TrimSpace(seg)
+		if i := strings.IndexAny(key, "=:"); i >= 0 {
+			key = strings.TrimSpace(key[:i])
+		}
+		for _, rt := range relationTags {
+			if key == rt {
+				return true
+			}
+		}
+	}
+	return false
+}
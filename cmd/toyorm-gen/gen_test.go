@@ -0,0 +1,56 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		types    []string
+		wantFile string
+		wantErr  error
+	}{
+		{
+			name:     "models",
+			types:    []string{"User", "Order"},
+			wantFile: "testdata/model_toyorm.golden",
+		},
+		{
+			name:    "not found",
+			types:   []string{"Invalid"},
+			wantErr: errors.New("找不到结构体 Invalid"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			src, err := generate("testdata", tc.types)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			want, err := os.ReadFile(tc.wantFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, string(want), string(src))
+		})
+	}
+}
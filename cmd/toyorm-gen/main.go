@@ -0,0 +1,63 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// toyorm-gen 为模型生成类型安全的列、预先注册模型的代码，
+// 以及不需要反射的字段读写方法。一般通过 go generate 调用：
+//
+//	//go:generate go run github.com/flycash/toy-orm/cmd/toyorm-gen -type=User,Order
+//
+// 生成的代码在当前目录下，文件名默认是 <当前文件>_toyorm.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	typeNames := flag.String("type", "", "逗号分隔的结构体名字，必填")
+	output := flag.String("output", "", "输出文件，默认是 <当前文件>_toyorm.go")
+	flag.Parse()
+	if *typeNames == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+	src, err := generate(dir, strings.Split(*typeNames, ","))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "toyorm-gen:", err)
+		os.Exit(1)
+	}
+
+	out := *output
+	if out == "" {
+		// go generate 会设置 GOFILE
+		base := strings.TrimSuffix(os.Getenv("GOFILE"), ".go")
+		if base == "" {
+			base = strings.ToLower(strings.Split(*typeNames, ",")[0])
+		}
+		out = filepath.Join(dir, base+"_toyorm.go")
+	}
+	if err = os.WriteFile(out, src, 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, "toyorm-gen:", err)
+		os.Exit(1)
+	}
+}
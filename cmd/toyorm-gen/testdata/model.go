@@ -0,0 +1,28 @@
+package model
+
+import "database/sql"
+
+type User struct {
+	Id              int64
+	FirstName, Last string
+	Age             int8
+	Nick            *sql.NullString
+	CreateTime      int64    `orm:"createTime"`
+	Orders          []*Order `orm:"hasMany;fk=user_id"`
+	Roles           []*Role  `orm:"many2many:user_roles"`
+}
+
+type Order struct {
+	Id     int64
+	UserId int64
+	User   *User `orm:"belongsTo"`
+}
+
+type Role struct {
+	Id   int64
+	Name string
+}
+
+type unused struct {
+	name string
+}
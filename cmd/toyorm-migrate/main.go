@@ -0,0 +1,97 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// toyorm-migrate 执行目录里面的 SQL 迁移脚本：
+//
+//	toyorm-migrate -driver=sqlite3 -dsn=file:app.db -dir=migrations up
+//	toyorm-migrate -dsn=file:app.db down
+//	toyorm-migrate -dsn=file:app.db goto 3
+//	toyorm-migrate -dsn=file:app.db status
+//
+// 目前只编译了 sqlite3 驱动，其它数据库需要在自己的程序里面使用 migrate 包
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/flycash/toy-orm/migrate"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func main() {
+	driver := flag.String("driver", "sqlite3", "数据库驱动")
+	dsn := flag.String("dsn", "", "数据库连接，必填")
+	dir := flag.String("dir", "migrations", "迁移脚本所在的目录")
+	table := flag.String("table", "schema_migrations", "记录迁移版本的表")
+	flag.Parse()
+	if *dsn == "" || flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	db, err := sql.Open(*driver, *dsn)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "toyorm-migrate:", err)
+		os.Exit(1)
+	}
+	defer func() { _ = db.Close() }()
+	m := migrate.New(db, migrate.WithTable(*table))
+	if err = m.Load(os.DirFS(*dir)); err == nil {
+		err = run(context.Background(), m, flag.Args(), os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "toyorm-migrate:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, m *migrate.Migrator, args []string, out io.Writer) error {
+	switch args[0] {
+	case "up":
+		return m.Up(ctx)
+	case "down":
+		return m.Down(ctx)
+	case "goto":
+		if len(args) < 2 {
+			return errors.New("goto 需要版本号")
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("非法的版本号 %s", args[1])
+		}
+		return m.Goto(ctx, version)
+	case "status":
+		sts, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, st := range sts {
+			appliedAt := "pending"
+			if st.Applied {
+				appliedAt = st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(out, "%d\t%s\t%s\n", st.Version, st.Name, appliedAt)
+		}
+		return nil
+	default:
+		return fmt.Errorf("未知的命令 %s", args[0])
+	}
+}
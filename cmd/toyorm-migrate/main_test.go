@@ -0,0 +1,97 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/flycash/toy-orm/migrate"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestRun(t *testing.T) {
+	db, err := sql.Open("sqlite3", "file:toyorm_migrate.db?cache=shared&mode=memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	m := migrate.New(db)
+	err = m.Load(fstest.MapFS{
+		"0001_create_user.up.sql":   {Data: []byte("CREATE TABLE `user`(`id` INTEGER PRIMARY KEY);")},
+		"0001_create_user.down.sql": {Data: []byte("DROP TABLE `user`;")},
+		"0002_create_order.up.sql":  {Data: []byte("CREATE TABLE `order`(`id` INTEGER PRIMARY KEY);")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	testCases := []struct {
+		name    string
+		args    []string
+		want    []string
+		wantErr error
+	}{
+		{
+			name: "goto",
+			args: []string{"goto", "1"},
+			want: []string{"1\tcreate_user\t", "2\tcreate_order\tpending"},
+		},
+		{
+			name: "up",
+			args: []string{"up"},
+			want: []string{"1\tcreate_user\t", "2\tcreate_order\t"},
+		},
+		{
+			// 0002 没有 down 脚本
+			name:    "down",
+			args:    []string{"down"},
+			wantErr: errors.New("toy-orm: 迁移 2_create_order 不能回滚"),
+		},
+		{
+			name:    "invalid version",
+			args:    []string{"goto", "abc"},
+			wantErr: errors.New("非法的版本号 abc"),
+		},
+		{
+			name:    "unknown command",
+			args:    []string{"redo"},
+			wantErr: errors.New("未知的命令 redo"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := run(ctx, m, tc.args, &bytes.Buffer{})
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			out := &bytes.Buffer{}
+			if err = run(ctx, m, []string{"status"}, out); err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			assert.Equal(t, len(tc.want), len(lines))
+			for i, prefix := range tc.want {
+				assert.True(t, strings.HasPrefix(lines[i], prefix), lines[i])
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// toyorm-reverse 读取已有数据库的表结构，生成对应的模型：
+//
+//	toyorm-reverse -driver=sqlite3 -dsn=file:app.db -pkg=model -output=model/model.go
+//	toyorm-reverse -dsn=file:app.db -tables=user,order
+//
+// 字段名经过 lesson 的命名规则转换之后和列名不一致的时候，会加上 column 标签；
+// 可以为 NULL 的列使用 sql.NullXXX 或者指针。
+// 目前只编译了 sqlite3 驱动
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/flycash/toy-orm/lesson"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func main() {
+	driver := flag.String("driver", "sqlite3", "数据库驱动")
+	dsn := flag.String("dsn", "", "数据库连接，必填")
+	pkg := flag.String("pkg", "model", "生成代码的包名")
+	tables := flag.String("tables", "", "逗号分隔的表名，默认是所有的表")
+	output := flag.String("output", "", "输出文件，默认输出到标准输出")
+	flag.Parse()
+	if *dsn == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	db, err := lesson.NewDB(*driver, *dsn)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "toyorm-reverse:", err)
+		os.Exit(1)
+	}
+	var names []string
+	if *tables != "" {
+		names = strings.Split(*tables, ",")
+	}
+	src, err := generate(context.Background(), db, *pkg, names)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "toyorm-reverse:", err)
+		os.Exit(1)
+	}
+	if *output == "" {
+		_, err = os.Stdout.Write(src)
+	} else {
+		err = os.WriteFile(*output, src, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "toyorm-reverse:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,245 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/format"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/flycash/toy-orm/lesson"
+)
+
+type field struct {
+	Name string
+	Type string
+	Tag  string
+}
+
+type model struct {
+	Name  string
+	Table string
+	// TableName 为 true 的时候，表名不能由类型名推导出来，需要生成 TableName 方法
+	TableName bool
+	Fields    []field
+}
+
+type file struct {
+	Package string
+	Imports []string
+	Models  []model
+}
+
+var tpl = template.Must(template.New("toyorm-reverse").Parse(`// 由 toyorm-reverse 根据表结构生成，生成之后可以按需修改
+
+package {{.Package}}
+{{if .Imports}}
+import (
+{{- range .Imports}}
+	"{{.}}"
+{{- end}}
+)
+{{end}}
+{{- range .Models}}
+type {{.Name}} struct {
+{{- range .Fields}}
+	{{.Name}} {{.Type}}{{if .Tag}} ` + "`orm:\"{{.Tag}}\"`" + `{{end}}
+{{- end}}
+}
+{{if .TableName}}
+func ({{.Name}}) TableName() string {
+	return "{{.Table}}"
+}
+{{end}}
+{{- end}}`))
+
+// generate 读取 tables 的表结构并生成结构体，tables 为空的时候使用所有的表
+func generate(ctx context.Context, db *lesson.DB, pkg string, tables []string) ([]byte, error) {
+	if len(tables) == 0 {
+		var err error
+		if tables, err = db.Tables(ctx); err != nil {
+			return nil, err
+		}
+	}
+	res := file{Package: pkg}
+	imports := make(map[string]bool, 2)
+	for _, table := range tables {
+		ts, err := db.TableSchema(ctx, table)
+		if err != nil {
+			return nil, fmt.Errorf("读取表 %s 失败: %w", table, err)
+		}
+		m, err := buildModel(ts, imports)
+		if err != nil {
+			return nil, err
+		}
+		res.Models = append(res.Models, m)
+	}
+	for _, imp := range []string{"database/sql", "time"} {
+		if imports[imp] {
+			res.Imports = append(res.Imports, imp)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, res); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+func buildModel(ts *lesson.TableSchema, imports map[string]bool) (model, error) {
+	m := model{Name: camelName(ts.Name), Table: ts.Name}
+	m.TableName = underscoreName(m.Name) != ts.Name
+	pks := 0
+	for _, c := range ts.Columns {
+		if c.PK {
+			pks++
+		}
+	}
+	names := make(map[string]bool, len(ts.Columns))
+	for _, c := range ts.Columns {
+		typ, err := goType(c)
+		if err != nil {
+			return m, fmt.Errorf("表 %s 的列 %s: %w", ts.Name, c.Name, err)
+		}
+		if strings.HasPrefix(typ, "sql.") {
+			imports["database/sql"] = true
+		} else if strings.Contains(typ, "time.") {
+			imports["time"] = true
+		}
+
+		name := camelName(c.Name)
+		for i := 2; names[name]; i++ {
+			name = fmt.Sprintf("%s%d", camelName(c.Name), i)
+		}
+		names[name] = true
+
+		var tags []string
+		if underscoreName(name) != c.Name {
+			tags = append(tags, "column="+c.Name)
+		}
+		// 没有 pk 标签的时候 Id 就是主键
+		if c.PK && (pks > 1 || name != "Id") {
+			tags = append(tags, "pk")
+		}
+		m.Fields = append(m.Fields, field{Name: name, Type: typ, Tag: strings.Join(tags, ";")})
+	}
+	return m, nil
+}
+
+// nullTypes 是可以为 NULL 的列对应的类型，不在这里面的类型使用指针
+var nullTypes = map[string]string{
+	"bool":      "sql.NullBool",
+	"uint8":     "sql.NullByte",
+	"int16":     "sql.NullInt16",
+	"int32":     "sql.NullInt32",
+	"int64":     "sql.NullInt64",
+	"float64":   "sql.NullFloat64",
+	"string":    "sql.NullString",
+	"time.Time": "sql.NullTime",
+}
+
+// goType 把 MySQL 或者 SQLite 的列类型映射为 Go 类型
+func goType(c lesson.ColumnSchema) (string, error) {
+	typ := strings.ToUpper(c.Type)
+	unsigned := strings.Contains(typ, "UNSIGNED")
+	base := typ
+	if i := strings.IndexAny(base, "( "); i >= 0 {
+		base = base[:i]
+	}
+	var res string
+	switch {
+	case strings.HasPrefix(typ, "TINYINT(1)") || base == "BOOL" || base == "BOOLEAN":
+		res = "bool"
+	case base == "TINYINT":
+		res = "int8"
+	case base == "SMALLINT":
+		res = "int16"
+	case base == "MEDIUMINT" || base == "INT":
+		res = "int32"
+	// SQLite 的 INTEGER 是 64 位的
+	case base == "BIGINT" || base == "INTEGER":
+		res = "int64"
+	case base == "FLOAT":
+		res = "float32"
+	case base == "DOUBLE" || base == "REAL":
+		res = "float64"
+	// DECIMAL 使用字符串避免丢失精度
+	case base == "DECIMAL" || base == "NUMERIC" || base == "TIME" || base == "ENUM" || base == "SET" || base == "JSON" ||
+		strings.Contains(base, "CHAR") || strings.Contains(base, "TEXT") || strings.Contains(base, "CLOB"):
+		res = "string"
+	case strings.Contains(base, "BLOB") || strings.Contains(base, "BINARY"):
+		return "[]byte", nil
+	case base == "DATE" || base == "DATETIME" || base == "TIMESTAMP":
+		res = "time.Time"
+	default:
+		return "", fmt.Errorf("不支持的列类型 %s", c.Type)
+	}
+	if unsigned && strings.HasPrefix(res, "int") {
+		res = "u" + res
+	}
+	if c.NotNull || c.PK {
+		return res, nil
+	}
+	if nt, ok := nullTypes[res]; ok {
+		return nt, nil
+	}
+	return "*" + res, nil
+}
+
+// camelName 把列名或者表名转换为导出的 Go 名字，例如 user_name 转换为 UserName
+func camelName(name string) string {
+	var sb strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if sb.Len() == 0 && unicode.IsDigit(r) {
+			sb.WriteByte('F')
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		sb.WriteRune(r)
+	}
+	if sb.Len() == 0 {
+		return "F"
+	}
+	return sb.String()
+}
+
+// underscoreName 和 lesson 里面推导列名、表名的规则保持一致，
+// 用于判断生成的名字能不能转换回原来的列名
+func underscoreName(name string) string {
+	var buf []byte
+	for i, v := range name {
+		if unicode.IsUpper(v) {
+			if i != 0 {
+				buf = append(buf, '_')
+			}
+			buf = append(buf, byte(unicode.ToLower(v)))
+		} else {
+			buf = append(buf, byte(v))
+		}
+	}
+	return string(buf)
+}
@@ -0,0 +1,114 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/flycash/toy-orm/lesson"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	const dsn = "file:reverse.db?cache=shared&mode=memory"
+	// 共享缓存的内存数据库，sqlDB 建的表 db 也能看到
+	sqlDB, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = sqlDB.Close() }()
+	for _, stmt := range []string{
+		"CREATE TABLE `user`(`id` INTEGER PRIMARY KEY AUTOINCREMENT, `user_name` TEXT NOT NULL, " +
+			"`Email` VARCHAR(128), `age` INTEGER, `score` REAL NOT NULL, `avatar` BLOB, " +
+			"`created_at` DATETIME NOT NULL, `deleted_at` DATETIME, `is_active` BOOLEAN);",
+		"CREATE TABLE `t_order_2`(`order_id` INTEGER NOT NULL, `item_no` INTEGER NOT NULL, " +
+			"`price` DECIMAL(10,2), PRIMARY KEY(`order_id`,`item_no`));",
+		"CREATE TABLE `geo`(`id` INTEGER PRIMARY KEY, `shape` GEOMETRY);",
+	} {
+		if _, err = sqlDB.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db, err := lesson.NewDB("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name     string
+		tables   []string
+		wantFile string
+		wantErr  error
+	}{
+		{
+			name:     "tables",
+			tables:   []string{"t_order_2", "user"},
+			wantFile: "testdata/model.golden",
+		},
+		{
+			name:    "unsupported type",
+			tables:  []string{"geo"},
+			wantErr: errors.New("表 geo 的列 shape: 不支持的列类型 GEOMETRY"),
+		},
+		{
+			name:    "not found",
+			tables:  []string{"invalid"},
+			wantErr: errors.New("读取表 invalid 失败: toy-orm: 表不存在"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			src, err := generate(context.Background(), db, "model", tc.tables)
+			if tc.wantErr != nil {
+				assert.EqualError(t, err, tc.wantErr.Error())
+				return
+			}
+			assert.Nil(t, err)
+			want, err := os.ReadFile(tc.wantFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, string(want), string(src))
+		})
+	}
+}
+
+func TestGoType(t *testing.T) {
+	testCases := []struct {
+		name string
+		col  lesson.ColumnSchema
+		want string
+	}{
+		{name: "mysql bool", col: lesson.ColumnSchema{Type: "tinyint(1)", NotNull: true}, want: "bool"},
+		{name: "mysql unsigned", col: lesson.ColumnSchema{Type: "int(10) unsigned", NotNull: true}, want: "uint32"},
+		{name: "mysql nullable tinyint", col: lesson.ColumnSchema{Type: "tinyint(4)"}, want: "*int8"},
+		{name: "mysql varchar", col: lesson.ColumnSchema{Type: "varchar(255)"}, want: "sql.NullString"},
+		{name: "mysql datetime", col: lesson.ColumnSchema{Type: "datetime(3)", NotNull: true}, want: "time.Time"},
+		{name: "mysql nullable float", col: lesson.ColumnSchema{Type: "float"}, want: "*float32"},
+		{name: "mysql varbinary", col: lesson.ColumnSchema{Type: "varbinary(16)"}, want: "[]byte"},
+		// 主键一定不为 NULL
+		{name: "primary key", col: lesson.ColumnSchema{Type: "bigint(20)", PK: true}, want: "int64"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			typ, err := goType(tc.col)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, typ)
+		})
+	}
+}
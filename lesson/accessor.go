@@ -0,0 +1,36 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import "reflect"
+
+// FieldAccessor 由 toyorm-gen 为模型生成，用来代替反射读写字段。
+// 模型的指针实现了这个接口的时候，
+// 结果集会直接扫描到 FieldPtr 返回的指针里面，
+// 插入和更新的时候也会通过 FieldValue 取值
+type FieldAccessor interface {
+	// FieldPtr 返回字段的指针，name 是字段名，不存在的时候返回 nil
+	FieldPtr(name string) any
+	// FieldValue 返回字段的值，name 是字段名，不存在的时候返回 nil
+	FieldValue(name string) any
+}
+
+// fieldValue 读取结构体 val 的字段，优先使用 FieldAccessor
+func fieldValue(val reflect.Value, name string) any {
+	if fa, ok := val.Addr().Interface().(FieldAccessor); ok {
+		return fa.FieldValue(name)
+	}
+	return val.FieldByName(name).Interface()
+}
@@ -0,0 +1,95 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"testing"
+)
+
+// AccessorModel 模拟 toyorm-gen 生成的代码，
+// FieldValue 故意给 Name 加上前缀，用来确认确实走了生成的代码
+type AccessorModel struct {
+	Id   int64
+	Name string
+}
+
+func (m *AccessorModel) FieldPtr(name string) any {
+	switch name {
+	case "Id":
+		return &m.Id
+	case "Name":
+		return &m.Name
+	}
+	return nil
+}
+
+func (m *AccessorModel) FieldValue(name string) any {
+	switch name {
+	case "Id":
+		return m.Id
+	case "Name":
+		return "generated " + m.Name
+	}
+	return nil
+}
+
+func TestFieldAccessor(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q, err := NewInserter[AccessorModel](db).Values(&AccessorModel{Id: 1, Name: "Tom"}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []any{int64(1), "generated Tom"}, q.Args)
+
+	mock.ExpectQuery("SELECT .*").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Tom"))
+	am, err := NewSelector[AccessorModel](db).Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, &AccessorModel{Id: 1, Name: "Tom"}, am)
+}
+
+func TestRegister(t *testing.T) {
+	type PreRegisteredModel struct {
+		Id int64
+	}
+	MustRegister(&PreRegisteredModel{})
+	mi, ok := defaultRegistry.models.Load(reflect.TypeOf(&PreRegisteredModel{}))
+	assert.True(t, ok)
+
+	// 所有的 DB 都使用预先注册的元数据
+	r := &registry{}
+	res, err := r.get(&PreRegisteredModel{})
+	assert.Nil(t, err)
+	assert.Same(t, mi, res)
+
+	assert.Panics(t, func() {
+		MustRegister(PreRegisteredModel{})
+	})
+}
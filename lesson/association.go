@@ -0,0 +1,208 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// Association 维护 many2many 关联的中间表，所有的语句都在事务里面执行。
+// 它只会插入或者删除中间表的数据，关联模型本身必须已经存在。
+// 操作成功之后，owner 上对应的字段也会被同步修改
+type Association struct {
+	tx    *Tx
+	owner reflect.Value
+	mi    *ModelInfo
+	rel   *relation
+	// ownerKey 是 owner 被中间表引用的列的值
+	ownerKey any
+	// fi 是关联模型被中间表引用的字段
+	fi  *FieldInfo
+	err error
+}
+
+// Association 例如 tx.Association(&user, "Roles").Append(ctx, &role)
+func (t *Tx) Association(owner any, field string) *Association {
+	a := &Association{tx: t, owner: reflect.ValueOf(owner)}
+	a.mi, a.err = t.getCore().r.get(owner)
+	if a.err != nil {
+		return a
+	}
+	rel, ok := a.mi.relations[field]
+	if !ok || rel.kind != manyToMany {
+		a.err = fmt.Errorf("toy-orm: %s 不是多对多关联", field)
+		return a
+	}
+	a.rel = rel
+	var pfi *FieldInfo
+	_, pfi, a.fi, a.err = rel.fields(t.getCore(), a.mi)
+	if a.err != nil {
+		return a
+	}
+	a.ownerKey, ok = normalizeKey(a.owner.Elem().FieldByName(pfi.fieldName))
+	if !ok {
+		a.err = fmt.Errorf("toy-orm: 关联 %s 的列 %s 为 NULL", field, pfi.columnName)
+	}
+	return a
+}
+
+// Append 在中间表里面插入 owner 和 targets 的关联
+func (a *Association) Append(ctx context.Context, targets ...any) error {
+	if a.err != nil {
+		return a.err
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+	keys, err := a.targetKeys(targets)
+	if err != nil {
+		return err
+	}
+	b := &builder{}
+	b.sb.WriteString("INSERT INTO ")
+	b.quote(a.rel.joinTable)
+	b.sb.WriteByte('(')
+	b.quote(a.rel.fk)
+	b.sb.WriteByte(',')
+	b.quote(a.rel.assocFk)
+	b.sb.WriteString(") VALUES")
+	for i, key := range keys {
+		if i > 0 {
+			b.sb.WriteByte(',')
+		}
+		b.sb.WriteByte('(')
+		b.addArg(a.ownerKey)
+		b.sb.WriteByte(',')
+		b.addArg(key)
+		b.sb.WriteByte(')')
+	}
+	b.sb.WriteByte(';')
+	if _, err = a.tx.exec(ctx, b.sb.String(), b.args...); err != nil {
+		return err
+	}
+
+	fd := a.owner.Elem().FieldByName(a.rel.fieldName)
+	res := fd
+	for _, t := range targets {
+		res = reflect.Append(res, a.elemOf(fd, t))
+	}
+	fd.Set(res)
+	return nil
+}
+
+// Remove 删除中间表里面 owner 和 targets 的关联
+func (a *Association) Remove(ctx context.Context, targets ...any) error {
+	if a.err != nil {
+		return a.err
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+	keys, err := a.targetKeys(targets)
+	if err != nil {
+		return err
+	}
+	if err = a.delete(ctx, C(a.rel.assocFk).In(keys...)); err != nil {
+		return err
+	}
+
+	removed := make(map[any]struct{}, len(keys))
+	for _, key := range keys {
+		removed[key] = struct{}{}
+	}
+	fd := a.owner.Elem().FieldByName(a.rel.fieldName)
+	res := reflect.MakeSlice(fd.Type(), 0, fd.Len())
+	for i := 0; i < fd.Len(); i++ {
+		key, ok := normalizeKey(reflect.Indirect(fd.Index(i)).FieldByName(a.fi.fieldName))
+		if _, has := removed[key]; ok && has {
+			continue
+		}
+		res = reflect.Append(res, fd.Index(i))
+	}
+	fd.Set(res)
+	return nil
+}
+
+// Replace 删除 owner 所有的关联，然后关联到 targets
+func (a *Association) Replace(ctx context.Context, targets ...any) error {
+	if a.err != nil {
+		return a.err
+	}
+	if _, err := a.targetKeys(targets); err != nil {
+		return err
+	}
+	if err := a.delete(ctx); err != nil {
+		return err
+	}
+	fd := a.owner.Elem().FieldByName(a.rel.fieldName)
+	fd.Set(reflect.MakeSlice(fd.Type(), 0, len(targets)))
+	return a.Append(ctx, targets...)
+}
+
+// delete 删除中间表里面 owner 的关联，ps 是额外的条件
+func (a *Association) delete(ctx context.Context, ps ...Predicate) error {
+	b := &builder{mi: a.rel.joinModel()}
+	b.sb.WriteString("DELETE FROM ")
+	b.quote(a.rel.joinTable)
+	where := append([]Predicate{C(a.rel.fk).EQ(a.ownerKey)}, ps...)
+	if err := b.buildWhere(where); err != nil {
+		return err
+	}
+	b.sb.WriteByte(';')
+	_, err := a.tx.exec(ctx, b.sb.String(), b.args...)
+	return err
+}
+
+// targetKeys 返回 targets 被中间表引用的列的值
+func (a *Association) targetKeys(targets []any) ([]any, error) {
+	typ := reflect.PtrTo(a.rel.elem)
+	keys := make([]any, 0, len(targets))
+	for _, t := range targets {
+		tv := reflect.ValueOf(t)
+		if tv.Type() != typ || tv.IsNil() {
+			return nil, fmt.Errorf("toy-orm: 关联 %s 只接受 %s", a.rel.fieldName, typ)
+		}
+		key, ok := normalizeKey(tv.Elem().FieldByName(a.fi.fieldName))
+		if !ok {
+			return nil, fmt.Errorf("toy-orm: 关联 %s 的列 %s 为 NULL", a.rel.fieldName, a.fi.columnName)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// elemOf 把 target 转换为字段 fd 的元素类型，也就是结构体或者结构体指针
+func (a *Association) elemOf(fd reflect.Value, target any) reflect.Value {
+	tv := reflect.ValueOf(target)
+	if fd.Type().Elem().Kind() == reflect.Ptr {
+		return tv
+	}
+	return tv.Elem()
+}
+
+// joinModel 把中间表当成一个只有两列的模型，字段名就是列名，
+// 这样就可以用 C(fk) 来构造中间表的查询条件
+func (r *relation) joinModel() *ModelInfo {
+	fk := &FieldInfo{columnName: r.fk, fieldName: r.fk}
+	assocFk := &FieldInfo{columnName: r.assocFk, fieldName: r.assocFk}
+	return &ModelInfo{
+		tableName: r.joinTable,
+		fields:    []string{r.fk, r.assocFk},
+		fieldMap:  map[string]*FieldInfo{r.fk: fk, r.assocFk: assocFk},
+		columnMap: map[string]*FieldInfo{r.fk: fk, r.assocFk: assocFk},
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+)
+
+func TestAssociation(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	admin, editor, viewer := &M2MRole{Id: 10}, &M2MRole{Id: 11}, &M2MRole{Id: 12}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `user_roles`(`user_id`,`role_id`) VALUES(?,?),(?,?);")).
+		WithArgs(int64(1), int64(10), int64(1), int64(11)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `user_roles` WHERE (`user_id` = ?) AND (`role_id` IN (?));")).
+		WithArgs(int64(1), int64(10)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `user_roles` WHERE `user_id` = ?;")).
+		WithArgs(int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `user_roles`(`user_id`,`role_id`) VALUES(?,?);")).
+		WithArgs(int64(1), int64(12)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	tx, err := db.Begin(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := &M2MUser{Id: 1}
+	err = tx.Association(u, "Roles").Append(ctx, admin, editor)
+	assert.Nil(t, err)
+	assert.Equal(t, []*M2MRole{admin, editor}, u.Roles)
+
+	err = tx.Association(u, "Roles").Remove(ctx, admin)
+	assert.Nil(t, err)
+	assert.Equal(t, []*M2MRole{editor}, u.Roles)
+
+	err = tx.Association(u, "Roles").Replace(ctx, viewer)
+	assert.Nil(t, err)
+	assert.Equal(t, []*M2MRole{viewer}, u.Roles)
+	assert.Nil(t, tx.Commit())
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestAssociation_Error(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	mock.ExpectBegin()
+	tx, err := db.Begin(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 不是多对多关联
+	err = tx.Association(&RelUser{Id: 1}, "Orders").Append(ctx, &RelOrder{Id: 1})
+	assert.Equal(t, errors.New("toy-orm: Orders 不是多对多关联"), err)
+
+	// 类型不对
+	err = tx.Association(&M2MUser{Id: 1}, "Roles").Append(ctx, &RelOrder{Id: 1})
+	assert.Equal(t, errors.New("toy-orm: 关联 Roles 只接受 *lesson.M2MRole"), err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
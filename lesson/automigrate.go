@@ -0,0 +1,160 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// MigrationPlan 是模型和表结构之间的差异
+type MigrationPlan struct {
+	// Statements 是补齐表结构需要执行的语句，只会新增表、列和索引
+	Statements []string
+	// Destructive 是需要人工处理的差异，例如列的类型变了，或者列已经不在模型里面。
+	// AutoMigrate 不会处理这些差异
+	Destructive []string
+}
+
+// PlanMigration 比较 models 和数据库里面的表结构，只返回执行计划而不修改数据库，
+// 也就是 AutoMigrate 的 dry-run 模式
+func (db *DB) PlanMigration(ctx context.Context, models ...any) (*MigrationPlan, error) {
+	plan := &MigrationPlan{}
+	for _, m := range models {
+		mi, err := db.r.get(m)
+		if err != nil {
+			return nil, err
+		}
+		ts, err := db.TableSchema(ctx, mi.tableName)
+		if errors.Is(err, ErrTableNotFound) {
+			stmts, err := createTableSQL(db.dialect, mi)
+			if err != nil {
+				return nil, err
+			}
+			plan.Statements = append(plan.Statements, stmts...)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err = plan.diff(db.dialect, mi, ts); err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
+
+// AutoMigrate 执行 PlanMigration 返回的语句，返回的执行计划里面的 Destructive 需要人工处理
+func (db *DB) AutoMigrate(ctx context.Context, models ...any) (*MigrationPlan, error) {
+	plan, err := db.PlanMigration(ctx, models...)
+	if err != nil {
+		return nil, err
+	}
+	for _, stmt := range plan.Statements {
+		if _, err = db.exec(ctx, stmt); err != nil {
+			return plan, err
+		}
+	}
+	return plan, nil
+}
+
+func (p *MigrationPlan) diff(d Dialect, mi *ModelInfo, ts *TableSchema) error {
+	cols := make(map[string]ColumnSchema, len(ts.Columns))
+	for _, c := range ts.Columns {
+		cols[c.Name] = c
+	}
+	for _, fn := range mi.fields {
+		fi := mi.fieldMap[fn]
+		c, ok := cols[fi.columnName]
+		if !ok {
+			if mi.isPK(fi) {
+				p.destructive("表 %s 缺少主键列 %s", mi.tableName, fi.columnName)
+				continue
+			}
+			// 已经有数据的表不能直接加上没有默认值的 NOT NULL 列，
+			// SQLite 会拒绝，MySQL 会填充隐式的零值
+			if fi.notNull && fi.dflt == nil {
+				p.destructive("表 %s 缺少 NOT NULL 列 %s，并且没有默认值", mi.tableName, fi.columnName)
+				continue
+			}
+			b := &builder{mi: mi}
+			b.sb.WriteString("ALTER TABLE ")
+			b.quote(mi.tableName)
+			b.sb.WriteString(" ADD COLUMN ")
+			if err := b.buildColumnDef(d, fi, false); err != nil {
+				return err
+			}
+			b.sb.WriteByte(';')
+			p.Statements = append(p.Statements, b.sb.String())
+			continue
+		}
+		delete(cols, fi.columnName)
+
+		typ, err := d.columnType(fi.typ, fi.size)
+		if err != nil {
+			return err
+		}
+		if d.normalizeType(c.Type) != d.normalizeType(typ) {
+			p.destructive("列 %s.%s 的类型是 %s，模型是 %s", mi.tableName, c.Name, c.Type, typ)
+		}
+		if notNull := fi.notNull || mi.isPK(fi); notNull != c.NotNull {
+			p.destructive("列 %s.%s 的 NOT NULL 约束和模型不一致", mi.tableName, c.Name)
+		}
+	}
+	for _, c := range ts.Columns {
+		if _, ok := cols[c.Name]; ok {
+			p.destructive("列 %s.%s 不在模型里面", mi.tableName, c.Name)
+		}
+	}
+
+	idxs := make(map[string]IndexSchema, len(ts.Indexes))
+	for _, idx := range ts.Indexes {
+		idxs[idx.Name] = idx
+	}
+	for _, idx := range mi.indexes {
+		have, ok := idxs[idx.name]
+		if !ok {
+			p.Statements = append(p.Statements, createIndexSQL(mi.tableName, idx, false))
+			continue
+		}
+		delete(idxs, idx.name)
+		if have.Unique != idx.unique || !equalStrings(have.Columns, idx.columns) {
+			p.destructive("索引 %s.%s 和模型不一致", mi.tableName, idx.name)
+		}
+	}
+	for _, idx := range ts.Indexes {
+		if _, ok := idxs[idx.Name]; ok {
+			p.destructive("索引 %s.%s 不在模型里面", mi.tableName, idx.Name)
+		}
+	}
+	return nil
+}
+
+func (p *MigrationPlan) destructive(format string, args ...any) {
+	p.Destructive = append(p.Destructive, fmt.Sprintf(format, args...))
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,200 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type MigrateUser struct {
+	Id    int64
+	Name  string
+	Email string `orm:"unique"`
+	Age   int8   `orm:"index"`
+}
+
+type MigrateOrder struct {
+	Id     int64
+	UserId int64
+}
+
+func TestDB_AutoMigrate_SQLite(t *testing.T) {
+	db, err := NewDB("sqlite3", "file:automigrate.db?cache=shared&mode=memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.db.Close() }()
+	ctx := context.Background()
+	for _, stmt := range []string{
+		"CREATE TABLE `migrate_user`(`id` INTEGER NOT NULL PRIMARY KEY, `name` INTEGER, `legacy` TEXT);",
+		"CREATE INDEX `idx_legacy` ON `migrate_user` (`legacy`);",
+	} {
+		if _, err = db.exec(ctx, stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wantDestructive := []string{
+		"列 migrate_user.name 的类型是 INTEGER，模型是 TEXT",
+		"列 migrate_user.legacy 不在模型里面",
+		"索引 migrate_user.idx_legacy 不在模型里面",
+	}
+	// dry-run 不会修改数据库
+	plan, err := db.PlanMigration(ctx, &MigrateUser{}, &MigrateOrder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, &MigrationPlan{
+		Statements: []string{
+			"ALTER TABLE `migrate_user` ADD COLUMN `email` TEXT;",
+			"ALTER TABLE `migrate_user` ADD COLUMN `age` INTEGER;",
+			"CREATE UNIQUE INDEX `uk_migrate_user_email` ON `migrate_user` (`email`);",
+			"CREATE INDEX `idx_migrate_user_age` ON `migrate_user` (`age`);",
+			"CREATE TABLE IF NOT EXISTS `migrate_order`(`id` INTEGER NOT NULL PRIMARY KEY, `user_id` INTEGER);",
+		},
+		Destructive: wantDestructive,
+	}, plan)
+	_, err = db.TableSchema(ctx, "migrate_order")
+	assert.Equal(t, ErrTableNotFound, err)
+
+	_, err = db.AutoMigrate(ctx, &MigrateUser{}, &MigrateOrder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables, err := db.Tables(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"migrate_order", "migrate_user"}, tables)
+	ts, err := db.TableSchema(ctx, "migrate_user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, &TableSchema{
+		Name: "migrate_user",
+		Columns: []ColumnSchema{
+			{Name: "id", Type: "INTEGER", NotNull: true, PK: true},
+			{Name: "name", Type: "INTEGER"},
+			{Name: "legacy", Type: "TEXT"},
+			{Name: "email", Type: "TEXT"},
+			{Name: "age", Type: "INTEGER"},
+		},
+		Indexes: []IndexSchema{
+			{Name: "idx_migrate_user_age", Columns: []string{"age"}},
+			{Name: "uk_migrate_user_email", Unique: true, Columns: []string{"email"}},
+			{Name: "idx_legacy", Columns: []string{"legacy"}},
+		},
+	}, ts)
+
+	// 再次执行的时候只剩下破坏性的差异
+	plan, err = db.AutoMigrate(ctx, &MigrateUser{}, &MigrateOrder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, &MigrationPlan{Destructive: wantDestructive}, plan)
+}
+
+type MigrateProduct struct {
+	Id    int64
+	Name  string `orm:"notNull"`
+	Stock int64  `orm:"notNull;default=0"`
+}
+
+func TestDB_AutoMigrate_NotNull(t *testing.T) {
+	db, err := NewDB("sqlite3", "file:automigrate_not_null.db?cache=shared&mode=memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.db.Close() }()
+	ctx := context.Background()
+	if _, err = db.exec(ctx, "CREATE TABLE `migrate_product`(`id` INTEGER NOT NULL PRIMARY KEY);"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 有默认值的 NOT NULL 列可以直接添加，没有默认值的需要人工处理
+	plan, err := db.AutoMigrate(ctx, &MigrateProduct{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, &MigrationPlan{
+		Statements: []string{
+			"ALTER TABLE `migrate_product` ADD COLUMN `stock` INTEGER NOT NULL DEFAULT 0;",
+		},
+		Destructive: []string{
+			"表 migrate_product 缺少 NOT NULL 列 name，并且没有默认值",
+		},
+	}, plan)
+}
+
+func TestDB_PlanMigration_MySQL(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cols := sqlmock.NewRows([]string{"COLUMN_NAME", "COLUMN_TYPE", "IS_NULLABLE", "COLUMN_KEY", "COLUMN_DEFAULT"})
+	// MySQL 5.7 会返回整数的显示宽度
+	cols.AddRow("id", "bigint(20)", "NO", "PRI", nil)
+	cols.AddRow("name", "varchar(64)", "YES", "", nil)
+	cols.AddRow("email", "varchar(255)", "YES", "UNI", nil)
+	mock.ExpectQuery("SELECT (.+) FROM `information_schema`.`COLUMNS`").
+		WithArgs("migrate_user").WillReturnRows(cols)
+	idxs := sqlmock.NewRows([]string{"INDEX_NAME", "NON_UNIQUE", "COLUMN_NAME"})
+	idxs.AddRow("PRIMARY", 0, "id")
+	idxs.AddRow("uk_email", 0, "email")
+	mock.ExpectQuery("SELECT (.+) FROM `information_schema`.`STATISTICS`").
+		WithArgs("migrate_user").WillReturnRows(idxs)
+
+	plan, err := db.PlanMigration(context.Background(), &MigrateUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, &MigrationPlan{
+		Statements: []string{
+			"ALTER TABLE `migrate_user` ADD COLUMN `age` TINYINT;",
+			"CREATE UNIQUE INDEX `uk_migrate_user_email` ON `migrate_user` (`email`);",
+			"CREATE INDEX `idx_migrate_user_age` ON `migrate_user` (`age`);",
+		},
+		Destructive: []string{
+			"列 migrate_user.name 的类型是 varchar(64)，模型是 VARCHAR(255)",
+			"索引 migrate_user.uk_email 不在模型里面",
+		},
+	}, plan)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestDB_TableSchema_MySQLNotFound(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectQuery("SELECT (.+) FROM `information_schema`.`COLUMNS`").
+		WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME"}))
+
+	_, err = db.TableSchema(context.Background(), "not_exist")
+	assert.Equal(t, ErrTableNotFound, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
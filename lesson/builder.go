@@ -0,0 +1,136 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// builder 是 Selector、Updater 等构造 SQL 的公共部分
+type builder struct {
+	sb   strings.Builder
+	args []any
+	mi   *ModelInfo
+}
+
+// reset 清空上一次 Build 留下来的状态，使得同一个 builder 可以多次 Build
+func (b *builder) reset() {
+	b.sb.Reset()
+	b.args = nil
+}
+
+func (b *builder) quote(name string) {
+	b.sb.WriteByte('`')
+	b.sb.WriteString(name)
+	b.sb.WriteByte('`')
+}
+
+// buildColumn 把字段名转换为列名
+func (b *builder) buildColumn(name string) error {
+	fi, ok := b.mi.fieldMap[name]
+	if !ok {
+		return fmt.Errorf("toy-orm: 非法列名 %s", name)
+	}
+	b.quote(fi.columnName)
+	return nil
+}
+
+// buildWhere 构造 WHERE 部分，没有查询条件的时候什么都不做
+func (b *builder) buildWhere(where []Predicate) error {
+	if len(where) == 0 {
+		return nil
+	}
+	b.sb.WriteString(" WHERE ")
+	return b.buildPredicates(where)
+}
+
+// buildPredicates 把多个 Predicate 用 AND 连接起来
+func (b *builder) buildPredicates(ps []Predicate) error {
+	p := ps[0]
+	for i := 1; i < len(ps); i++ {
+		p = p.And(ps[i])
+	}
+	return b.buildExpression(p)
+}
+
+func (b *builder) buildExpression(e Expression) error {
+	if e == nil {
+		return nil
+	}
+	switch exp := e.(type) {
+	case Column:
+		return b.buildColumn(exp.name)
+	case value:
+		b.addArg(exp.val)
+	case values:
+		if len(exp.vals) == 0 {
+			return errors.New("toy-orm: IN 的参数不能为空")
+		}
+		b.sb.WriteByte('(')
+		for i, val := range exp.vals {
+			if i > 0 {
+				b.sb.WriteByte(',')
+			}
+			b.addArg(val)
+		}
+		b.sb.WriteByte(')')
+	case RawExpr:
+		b.sb.WriteString(exp.raw)
+		b.args = append(b.args, exp.args...)
+	case Predicate:
+		// RawExpr.AsPredicate 只有左边
+		if exp.op == "" {
+			return b.buildExpression(exp.left)
+		}
+		_, lp := exp.left.(Predicate)
+		if lp {
+			b.sb.WriteByte('(')
+		}
+		if err := b.buildExpression(exp.left); err != nil {
+			return err
+		}
+		if lp {
+			b.sb.WriteByte(')')
+		}
+
+		b.sb.WriteByte(' ')
+		b.sb.WriteString(exp.op.String())
+		if exp.right == nil {
+			return nil
+		}
+		b.sb.WriteByte(' ')
+
+		_, rp := exp.right.(Predicate)
+		if rp {
+			b.sb.WriteByte('(')
+		}
+		if err := b.buildExpression(exp.right); err != nil {
+			return err
+		}
+		if rp {
+			b.sb.WriteByte(')')
+		}
+	default:
+		return fmt.Errorf("toy-orm: 不支持的表达式 %v", exp)
+	}
+	return nil
+}
+
+func (b *builder) addArg(val any) {
+	b.sb.WriteByte('?')
+	b.args = append(b.args, val)
+}
@@ -0,0 +1,231 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"database/sql"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ClusterDB 读写分离，查询发给从库，写操作和事务发给主库。
+// 模型、Scope、方言等配置使用主库的
+type ClusterDB struct {
+	primary  *DB
+	replicas []*replica
+	balancer Balancer
+	// cooldown 是从库被摘除之后，多久之后再重新尝试
+	cooldown time.Duration
+
+	mu   sync.Mutex
+	stop chan struct{}
+}
+
+type replica struct {
+	// downUntil 是 UnixNano，在这之前不会选中这个从库。
+	// 放在第一个保证 32 位平台上原子操作的对齐
+	downUntil int64
+	db        *DB
+}
+
+func (r *replica) available(now time.Time) bool {
+	return atomic.LoadInt64(&r.downUntil) <= now.UnixNano()
+}
+
+// NewClusterDB 默认使用轮询选择从库，没有从库的时候全部发给主库
+func NewClusterDB(primary *DB, replicas ...*DB) *ClusterDB {
+	res := &ClusterDB{
+		primary:  primary,
+		replicas: make([]*replica, 0, len(replicas)),
+		balancer: RoundRobin(),
+		cooldown: 30 * time.Second,
+	}
+	for _, r := range replicas {
+		res.replicas = append(res.replicas, &replica{db: r})
+	}
+	return res
+}
+
+// UseBalancer 指定选择从库的策略，例如 LeastConn()
+func (c *ClusterDB) UseBalancer(b Balancer) *ClusterDB {
+	c.balancer = b
+	return c
+}
+
+type forcePrimaryKey struct{}
+
+// ForcePrimary 返回的 ctx 上的查询都发给主库，用于读取刚刚写入的数据
+func ForcePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forcePrimaryKey{}, true)
+}
+
+func isForcePrimary(ctx context.Context) bool {
+	force, _ := ctx.Value(forcePrimaryKey{}).(bool)
+	return force
+}
+
+// Begin 在主库上开启事务，事务里面的查询也都发给主库
+func (c *ClusterDB) Begin(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	return c.primary.Begin(ctx, opts)
+}
+
+func (c *ClusterDB) query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if isForcePrimary(ctx) {
+		return c.primary.query(ctx, query, args...)
+	}
+	r := c.pick()
+	if r == nil {
+		return c.primary.query(ctx, query, args...)
+	}
+	rows, err := r.db.query(ctx, query, args...)
+	if err == nil || ctx.Err() != nil {
+		return rows, err
+	}
+	// ping 得通说明是 SQL 本身的错误，不是从库的问题
+	if r.db.db.PingContext(ctx) == nil {
+		return nil, err
+	}
+	c.markDown(r)
+	return c.primary.query(ctx, query, args...)
+}
+
+func (c *ClusterDB) exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return c.primary.exec(ctx, query, args...)
+}
+
+func (c *ClusterDB) getCore() core {
+	return c.primary.core
+}
+
+// pick 从可用的从库里面选一个，没有可用的从库时返回 nil
+func (c *ClusterDB) pick() *replica {
+	now := c.primary.clock()
+	candidates := make([]*replica, 0, len(c.replicas))
+	for _, r := range c.replicas {
+		if r.available(now) {
+			candidates = append(candidates, r)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	return c.balancer.pick(candidates)
+}
+
+func (c *ClusterDB) markDown(r *replica) {
+	atomic.StoreInt64(&r.downUntil, c.primary.clock().Add(c.cooldown).UnixNano())
+}
+
+// StartHealthCheck 每隔 interval ping 一次所有的从库，失败的摘除，恢复的重新加入。
+// 不调用的时候，被摘除的从库会在冷却时间之后重新尝试
+func (c *ClusterDB) StartHealthCheck(interval time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stop != nil {
+		return
+	}
+	c.stop = make(chan struct{})
+	go c.healthCheck(interval, c.stop)
+}
+
+func (c *ClusterDB) healthCheck(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		for _, r := range c.replicas {
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			err := r.db.db.PingContext(ctx)
+			cancel()
+			if err != nil {
+				c.markDown(r)
+			} else {
+				atomic.StoreInt64(&r.downUntil, 0)
+			}
+		}
+	}
+}
+
+// Close 停止健康检查，关闭主库和所有的从库
+func (c *ClusterDB) Close() error {
+	c.mu.Lock()
+	if c.stop != nil {
+		close(c.stop)
+		c.stop = nil
+	}
+	c.mu.Unlock()
+	err := c.primary.Close()
+	for _, r := range c.replicas {
+		if e := r.db.Close(); err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Balancer 是选择从库的策略
+type Balancer interface {
+	// pick 的 replicas 至少有一个
+	pick(replicas []*replica) *replica
+}
+
+// RoundRobin 轮流选择从库
+func RoundRobin() Balancer {
+	return &roundRobin{}
+}
+
+type roundRobin struct {
+	cnt uint64
+}
+
+func (b *roundRobin) pick(replicas []*replica) *replica {
+	n := atomic.AddUint64(&b.cnt, 1) - 1
+	return replicas[n%uint64(len(replicas))]
+}
+
+// Random 随机选择从库
+func Random() Balancer {
+	return randomBalancer{}
+}
+
+type randomBalancer struct{}
+
+func (randomBalancer) pick(replicas []*replica) *replica {
+	return replicas[rand.Intn(len(replicas))]
+}
+
+// LeastConn 选择正在使用的连接最少的从库
+func LeastConn() Balancer {
+	return leastConn{}
+}
+
+type leastConn struct{}
+
+func (leastConn) pick(replicas []*replica) *replica {
+	res, least := replicas[0], replicas[0].db.db.Stats().InUse
+	for _, r := range replicas[1:] {
+		if inUse := r.db.db.Stats().InUse; inUse < least {
+			res, least = r, inUse
+		}
+	}
+	return res
+}
@@ -0,0 +1,158 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestCluster 使用本地的 SQLite 文件作为主库和从库，
+// 每个库里面 id 为 1 的数据的 FirstName 是库的名字，用来判断查询发给了哪个库
+func newTestCluster(t *testing.T, replicas ...string) *ClusterDB {
+	dir := t.TempDir()
+	open := func(name string) *DB {
+		db, err := NewDB("sqlite3", filepath.Join(dir, name+".db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx := context.Background()
+		if err = db.CreateTables(ctx, &TestModel{}); err != nil {
+			t.Fatal(err)
+		}
+		_, err = NewInserter[TestModel](db).Values(&TestModel{Id: 1, FirstName: name}).Exec(ctx).RowsAffected()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	}
+	rs := make([]*DB, 0, len(replicas))
+	for _, name := range replicas {
+		rs = append(rs, open(name))
+	}
+	return NewClusterDB(open("primary"), rs...)
+}
+
+func whichDB(t *testing.T, ctx context.Context, sess Session) string {
+	tm, err := NewSelector[TestModel](sess).Where(C("Id").EQ(1)).Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tm.FirstName
+}
+
+func TestClusterDB(t *testing.T) {
+	cdb := newTestCluster(t, "r1", "r2")
+	defer func() { _ = cdb.Close() }()
+	ctx := context.Background()
+
+	// 查询轮流发给从库
+	var got []string
+	for i := 0; i < 4; i++ {
+		got = append(got, whichDB(t, ctx, cdb))
+	}
+	assert.Equal(t, []string{"r1", "r2", "r1", "r2"}, got)
+
+	// 写操作发给主库，ForcePrimary 之后可以读到刚刚写入的数据
+	_, err := NewUpdater[TestModel](cdb).Update(&TestModel{Id: 1, FirstName: "primary-updated"}).
+		Where(C("Id").EQ(1)).Exec(ctx).RowsAffected()
+	assert.Nil(t, err)
+	assert.Equal(t, "primary-updated", whichDB(t, ForcePrimary(ctx), cdb))
+	assert.Equal(t, "r1", whichDB(t, ctx, cdb))
+
+	// 事务里面的查询发给主库
+	tx, err := cdb.Begin(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "primary-updated", whichDB(t, ctx, tx))
+	assert.Nil(t, tx.Commit())
+
+	// SQL 本身的错误不会摘除从库
+	_, err = NewSelector[TestModel](cdb).Where(C("Invalid").EQ(1)).Get(ctx)
+	assert.Equal(t, errors.New("toy-orm: 非法列名 Invalid"), err)
+	_, err = RawQuery[TestModel](cdb, "SELECT * FROM `not_exist`").Get(ctx)
+	assert.NotNil(t, err)
+	assert.Equal(t, "r1", whichDB(t, ctx, cdb))
+}
+
+func TestClusterDB_LeastConn(t *testing.T) {
+	cdb := newTestCluster(t, "r1", "r2")
+	defer func() { _ = cdb.Close() }()
+	cdb.UseBalancer(LeastConn())
+	ctx := context.Background()
+
+	assert.Equal(t, "r1", whichDB(t, ctx, cdb))
+	// 游标没有关闭之前一直占用 r1 的连接
+	cur := NewSelector[TestModel](cdb).Iter(ctx)
+	assert.True(t, cur.Next())
+	assert.Equal(t, "r2", whichDB(t, ctx, cdb))
+	assert.Equal(t, "r2", whichDB(t, ctx, cdb))
+	assert.Nil(t, cur.Close())
+	assert.Equal(t, "r1", whichDB(t, ctx, cdb))
+
+	cdb.UseBalancer(Random())
+	assert.Contains(t, []string{"r1", "r2"}, whichDB(t, ctx, cdb))
+}
+
+func TestClusterDB_Unhealthy(t *testing.T) {
+	cdb := newTestCluster(t, "r1", "r2")
+	defer func() { _ = cdb.Close() }()
+	now := time.Now()
+	cdb.primary.clock = func() time.Time { return now }
+	ctx := context.Background()
+
+	// r1 不可用，这一次查询改为发给主库，之后摘除 r1
+	assert.Nil(t, cdb.replicas[0].db.db.Close())
+	assert.Equal(t, "primary", whichDB(t, ctx, cdb))
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "r2", whichDB(t, ctx, cdb))
+	}
+
+	// 冷却时间之后重新尝试 r1，仍然失败
+	now = now.Add(cdb.cooldown)
+	assert.ElementsMatch(t, []string{"r2", "primary"}, []string{whichDB(t, ctx, cdb), whichDB(t, ctx, cdb)})
+	assert.Equal(t, "r2", whichDB(t, ctx, cdb))
+
+	// 所有的从库都不可用的时候发给主库
+	assert.Nil(t, cdb.replicas[1].db.db.Close())
+	assert.Equal(t, "primary", whichDB(t, ctx, cdb))
+	assert.Equal(t, "primary", whichDB(t, ctx, cdb))
+}
+
+func TestClusterDB_HealthCheck(t *testing.T) {
+	cdb := newTestCluster(t, "r1")
+	defer func() { _ = cdb.Close() }()
+	ctx := context.Background()
+
+	// 健康检查会把恢复的从库重新加入
+	cdb.markDown(cdb.replicas[0])
+	assert.Equal(t, "primary", whichDB(t, ctx, cdb))
+	cdb.StartHealthCheck(10 * time.Millisecond)
+	assert.Eventually(t, func() bool {
+		return whichDB(t, ctx, cdb) == "r1"
+	}, time.Second, 10*time.Millisecond)
+
+	// ping 失败的从库会被摘除
+	assert.Nil(t, cdb.replicas[0].db.db.Close())
+	assert.Eventually(t, func() bool {
+		return !cdb.replicas[0].available(time.Now())
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "primary", whichDB(t, ctx, cdb))
+}
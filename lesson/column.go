@@ -16,10 +16,22 @@ package lesson
 
 type Column struct {
 	name string
+	// alias 只在 SELECT 的列里面生效
+	alias string
 }
 
 func (c Column) expr() {}
 
+func (c Column) assign() {}
+
+func (c Column) selectable() {}
+
+// As 例如 C("FirstName").As("name")
+func (c Column) As(alias string) Column {
+	c.alias = alias
+	return c
+}
+
 type value struct {
 	val any
 }
@@ -32,6 +44,13 @@ func valueOf(val any) value {
 	}
 }
 
+// values 代表 IN 后面的一组值
+type values struct {
+	vals []any
+}
+
+func (values) expr() {}
+
 func C(name string) Column {
 	return Column{name: name}
 }
@@ -60,3 +79,50 @@ func (c Column) GT(arg any) Predicate {
 		right: exprOf(arg),
 	}
 }
+
+// Like 例如 C("FirstName").Like("Tom%")
+func (c Column) Like(pattern string) Predicate {
+	return Predicate{
+		left:  c,
+		op:    opLIKE,
+		right: exprOf(pattern),
+	}
+}
+
+// IsNull 例如 C("DeletedAt").IsNull()
+func (c Column) IsNull() Predicate {
+	return Predicate{
+		left: c,
+		op:   opIsNull,
+	}
+}
+
+func (c Column) IsNotNull() Predicate {
+	return Predicate{
+		left: c,
+		op:   opIsNotNull,
+	}
+}
+
+// In 例如 C("Id").In(1, 2, 3)
+func (c Column) In(vals ...any) Predicate {
+	return Predicate{
+		left:  c,
+		op:    opIN,
+		right: values{vals: vals},
+	}
+}
+
+// OrderBy 是排序的列
+type OrderBy struct {
+	name string
+	desc bool
+}
+
+func Asc(name string) OrderBy {
+	return OrderBy{name: name}
+}
+
+func Desc(name string) OrderBy {
+	return OrderBy{name: name, desc: true}
+}
@@ -17,13 +17,38 @@ package lesson
 import (
 	"context"
 	"database/sql"
+	"reflect"
+	"time"
 )
 
 type DBOption func(*DB)
 
+// core 是 DB 和 Tx 共享的部分
+type core struct {
+	r       *registry
+	clock   func() time.Time
+	dialect Dialect
+
+	scopes      []Scope
+	modelScopes map[reflect.Type][]Scope
+	tenantField string
+
+	// useReflect 为 true 的时候使用反射来处理结果集，否则使用 unsafe
+	useReflect bool
+
+	// stmts 是预编译语句的缓存，为 nil 的时候不使用预编译语句
+	stmts *stmtCache
+	// qcache 是查询缓存，为 nil 的时候不缓存
+	qcache *queryCache
+}
+
+func (c core) getCore() core {
+	return c
+}
+
 type DB struct {
+	core
 	db *sql.DB
-	r  *registry
 }
 
 func NewDB(driver string, dsn string, opts ...DBOption) (*DB, error) {
@@ -31,13 +56,17 @@ func NewDB(driver string, dsn string, opts ...DBOption) (*DB, error) {
 	if err != nil {
 		return nil, err
 	}
-	return newDB(db, opts...)
+	return newDB(db, append([]DBOption{DBWithDialect(dialectOf(driver))}, opts...)...)
 }
 
 func newDB(db *sql.DB, opts ...DBOption) (*DB, error) {
 	res := &DB{
+		core: core{
+			r:       &registry{},
+			clock:   time.Now,
+			dialect: MySQL,
+		},
 		db: db,
-		r:  &registry{},
 	}
 	for _, o := range opts {
 		o(res)
@@ -45,31 +74,90 @@ func newDB(db *sql.DB, opts ...DBOption) (*DB, error) {
 	return res, nil
 }
 
+// Close 关闭缓存的预编译语句和数据库连接
+func (db *DB) Close() error {
+	if db.stmts != nil {
+		db.stmts.close()
+	}
+	return db.db.Close()
+}
+
 func (db *DB) Begin(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
 	tx, err := db.db.BeginTx(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
 	return &Tx{
-		r:  db.r,
-		tx: tx,
+		core: db.core,
+		tx:   tx,
 	}, nil
 }
 
-func (db *DB) query(ctx context.Context, sql string, args ...any) (*sql.Rows, error) {
-	return db.db.QueryContext(ctx, sql, args...)
+func (db *DB) query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if db.stmts == nil {
+		return db.db.QueryContext(ctx, query, args...)
+	}
+	e, err := db.stmts.get(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer db.stmts.release(e)
+	return e.stmt.QueryContext(ctx, args...)
 }
 
-func (db *DB) exec(ctx context.Context, sql string, args ...any) (sql.Result, error) {
-	return db.db.ExecContext(ctx, sql, args...)
+func (db *DB) exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if db.stmts == nil {
+		return db.db.ExecContext(ctx, query, args...)
+	}
+	e, err := db.stmts.get(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer db.stmts.release(e)
+	return e.stmt.ExecContext(ctx, args...)
+}
+
+// StmtCacheStats 返回预编译语句缓存的统计信息，没有开启缓存的时候返回零值
+func (db *DB) StmtCacheStats() StmtCacheStats {
+	if db.stmts == nil {
+		return StmtCacheStats{}
+	}
+	return db.stmts.snapshot()
+}
+
+// DBWithClock 指定获取当前时间的方法，主要用于测试
+func DBWithClock(clock func() time.Time) DBOption {
+	return func(db *DB) {
+		db.clock = clock
+	}
 }
 
-func (db *DB) registry() *registry {
-	return db.r
+// DBWithDialect 指定方言，NewDB 会根据驱动名字选择默认的方言
+func DBWithDialect(d Dialect) DBOption {
+	return func(db *DB) {
+		db.dialect = d
+	}
+}
+
+// DBWithStmtCache 缓存最近使用的 size 个预编译语句，避免每次执行都重新解析 SQL。
+// 事务里面会通过 tx.StmtContext 复用这些语句
+func DBWithStmtCache(size int) DBOption {
+	return func(db *DB) {
+		if size > 0 {
+			db.stmts = newStmtCache(db.db, size)
+		}
+	}
+}
+
+// DBUseReflect 使用反射来处理结果集，默认使用 unsafe
+func DBUseReflect() DBOption {
+	return func(db *DB) {
+		db.useReflect = true
+	}
 }
 
 type Session interface {
 	query(ctx context.Context, sql string, args ...any) (*sql.Rows, error)
 	exec(ctx context.Context, sql string, args ...any) (sql.Result, error)
-	registry() *registry
+	getCore() core
 }
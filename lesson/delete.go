@@ -0,0 +1,104 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+)
+
+type Deleter[T any] struct {
+	builder
+	sess Session
+
+	where []Predicate
+	force bool
+}
+
+func NewDeleter[T any](sess Session) *Deleter[T] {
+	return &Deleter[T]{
+		sess: sess,
+	}
+}
+
+func (d *Deleter[T]) Where(ps ...Predicate) *Deleter[T] {
+	d.where = ps
+	return d
+}
+
+// ForceDelete 即便模型支持软删除，也真的把数据删掉
+func (d *Deleter[T]) ForceDelete() *Deleter[T] {
+	d.force = true
+	return d
+}
+
+func (d *Deleter[T]) Build() (*Query, error) {
+	return d.build(context.Background())
+}
+
+func (d *Deleter[T]) build(ctx context.Context) (*Query, error) {
+	var (
+		t   T
+		err error
+	)
+	d.reset()
+	c := d.sess.getCore()
+	d.mi, err = c.r.get(&t)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes, err := c.scopePredicates(ctx, reflect.TypeOf(&t), d.mi)
+	if err != nil {
+		return nil, err
+	}
+	where := append(d.where[:len(d.where):len(d.where)], scopes...)
+	if sd := d.mi.softDelete; sd != nil && !d.force {
+		// 软删除实际上是一个 UPDATE 语句，已经删除的数据不会再被更新
+		d.sb.WriteString("UPDATE ")
+		d.quote(d.mi.tableName)
+		d.sb.WriteString(" SET ")
+		d.quote(sd.columnName)
+		d.sb.WriteByte('=')
+		d.addArg(sd.deletedAt(c.clock()))
+		where = append(where, C(sd.fieldName).IsNull())
+	} else {
+		d.sb.WriteString("DELETE FROM ")
+		d.quote(d.mi.tableName)
+	}
+
+	if err = d.buildWhere(where); err != nil {
+		return nil, err
+	}
+	d.sb.WriteByte(';')
+	return &Query{
+		SQL:  d.sb.String(),
+		Args: d.args,
+	}, nil
+}
+
+func (d *Deleter[T]) Exec(ctx context.Context) sql.Result {
+	q, err := d.build(ctx)
+	if err != nil {
+		return Result{err: err}
+	}
+	res, err := d.sess.exec(ctx, q.SQL, q.Args...)
+	invalidateCache(ctx, d.sess, d.mi.tableName)
+	return Result{
+		err: err,
+		res: res,
+	}
+}
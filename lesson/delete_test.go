@@ -0,0 +1,119 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"database/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type SoftDeleteModel struct {
+	Id        int64
+	FirstName string
+	DeletedAt *time.Time `orm:"softdelete"`
+}
+
+func TestDeleter_Build(t *testing.T) {
+	type UnixSoftDeleteModel struct {
+		Id        int64
+		DeletedAt sql.NullInt64 `orm:"softdelete=second"`
+	}
+	now := time.Unix(1655000000, 0)
+	mockDB, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB, DBWithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		name     string
+		q        QueryBuilder
+		wantSQL  string
+		wantArgs []any
+		wantErr  error
+	}{
+		{
+			// 没有 WHERE
+			name:    "no where",
+			q:       NewDeleter[TestModel](db),
+			wantSQL: "DELETE FROM `test_model`;",
+		},
+		{
+			name:     "where",
+			q:        NewDeleter[TestModel](db).Where(C("Id").EQ(1)),
+			wantSQL:  "DELETE FROM `test_model` WHERE `id` = ?;",
+			wantArgs: []any{1},
+		},
+		{
+			// 软删除
+			name:     "soft delete",
+			q:        NewDeleter[SoftDeleteModel](db).Where(C("Id").EQ(1)),
+			wantSQL:  "UPDATE `soft_delete_model` SET `deleted_at`=? WHERE (`id` = ?) AND (`deleted_at` IS NULL);",
+			wantArgs: []any{&now, 1},
+		},
+		{
+			// 软删除，使用秒作为时间戳
+			name:     "soft delete unix",
+			q:        NewDeleter[UnixSoftDeleteModel](db),
+			wantSQL:  "UPDATE `unix_soft_delete_model` SET `deleted_at`=? WHERE `deleted_at` IS NULL;",
+			wantArgs: []any{sql.NullInt64{Int64: 1655000000, Valid: true}},
+		},
+		{
+			// 强制删除
+			name:     "force delete",
+			q:        NewDeleter[SoftDeleteModel](db).Where(C("Id").EQ(1)).ForceDelete(),
+			wantSQL:  "DELETE FROM `soft_delete_model` WHERE `id` = ?;",
+			wantArgs: []any{1},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantSQL, q.SQL)
+			assert.Equal(t, tc.wantArgs, q.Args)
+		})
+	}
+}
+
+func TestDeleter_Exec(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectExec("DELETE FROM `test_model`").WillReturnResult(sqlmock.NewResult(0, 3))
+	res := NewDeleter[TestModel](db).Where(C("Age").GT(18)).Exec(context.Background())
+	affected, err := res.RowsAffected()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(3), affected)
+}
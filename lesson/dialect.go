@@ -0,0 +1,205 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+)
+
+// Dialect 代表不同数据库之间的差异
+type Dialect interface {
+	// Name 返回方言的名字，例如 mysql
+	Name() string
+	// columnType 把 Go 类型映射为列类型，size 是标签里面的 size，没有的时候是 0
+	columnType(typ reflect.Type, size int) (string, error)
+	autoIncrement() string
+	// inlinePrimaryKey 为 true 的时候，单列主键直接写在列定义里面
+	inlinePrimaryKey() bool
+	// inlineIndex 为 true 的时候，索引写在 CREATE TABLE 里面，
+	// 否则使用单独的 CREATE INDEX 语句
+	inlineIndex() bool
+	// tables 返回当前数据库里面所有的表
+	tables(ctx context.Context, sess Session) ([]string, error)
+	// tableSchema 读取表结构，表不存在的时候返回 ErrTableNotFound
+	tableSchema(ctx context.Context, sess Session, table string) (*TableSchema, error)
+	// normalizeType 把列类型转换为统一的形式，用于比较表结构和模型
+	normalizeType(typ string) string
+	// noLimit 是不限制行数的 LIMIT，只有 OFFSET 的时候使用
+	noLimit() string
+}
+
+var (
+	MySQL  Dialect = mysqlDialect{}
+	SQLite Dialect = sqliteDialect{}
+)
+
+// dialectOf 根据驱动名字选择方言，不认识的驱动使用 MySQL
+func dialectOf(driver string) Dialect {
+	switch driver {
+	case "sqlite3", "sqlite":
+		return SQLite
+	default:
+		return MySQL
+	}
+}
+
+var (
+	nullStringType  = reflect.TypeOf(sql.NullString{})
+	nullInt32Type   = reflect.TypeOf(sql.NullInt32{})
+	nullInt16Type   = reflect.TypeOf(sql.NullInt16{})
+	nullByteType    = reflect.TypeOf(sql.NullByte{})
+	nullFloat64Type = reflect.TypeOf(sql.NullFloat64{})
+	nullBoolType    = reflect.TypeOf(sql.NullBool{})
+)
+
+// baseType 去掉指针以及 sql.NullXXX，返回真正存储的类型
+func baseType(typ reflect.Type) reflect.Type {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	switch typ {
+	case nullStringType:
+		return reflect.TypeOf("")
+	case nullInt64Type:
+		return reflect.TypeOf(int64(0))
+	case nullInt32Type:
+		return reflect.TypeOf(int32(0))
+	case nullInt16Type:
+		return reflect.TypeOf(int16(0))
+	case nullByteType:
+		return reflect.TypeOf(byte(0))
+	case nullFloat64Type:
+		return reflect.TypeOf(float64(0))
+	case nullBoolType:
+		return reflect.TypeOf(false)
+	case nullTimeType:
+		return timeType
+	}
+	return typ
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string {
+	return "mysql"
+}
+
+func (mysqlDialect) columnType(typ reflect.Type, size int) (string, error) {
+	typ = baseType(typ)
+	if typ == timeType {
+		return "DATETIME(3)", nil
+	}
+	var res string
+	switch typ.Kind() {
+	case reflect.Bool:
+		return "TINYINT(1)", nil
+	case reflect.Int8, reflect.Uint8:
+		res = "TINYINT"
+	case reflect.Int16, reflect.Uint16:
+		res = "SMALLINT"
+	case reflect.Int32, reflect.Uint32:
+		res = "INT"
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		res = "BIGINT"
+	case reflect.Float32:
+		return "FLOAT", nil
+	case reflect.Float64:
+		return "DOUBLE", nil
+	case reflect.String:
+		if size <= 0 {
+			size = 255
+		}
+		return fmt.Sprintf("VARCHAR(%d)", size), nil
+	case reflect.Slice:
+		if typ.Elem().Kind() != reflect.Uint8 {
+			return "", fmt.Errorf("toy-orm: 不支持的列类型 %s", typ)
+		}
+		if size > 0 {
+			return fmt.Sprintf("VARBINARY(%d)", size), nil
+		}
+		return "BLOB", nil
+	default:
+		return "", fmt.Errorf("toy-orm: 不支持的列类型 %s", typ)
+	}
+	switch typ.Kind() {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint, reflect.Uint64:
+		res += " UNSIGNED"
+	}
+	return res, nil
+}
+
+func (mysqlDialect) autoIncrement() string {
+	return "AUTO_INCREMENT"
+}
+
+// noLimit MySQL 不支持单独的 OFFSET，官方文档建议使用最大的 BIGINT UNSIGNED
+func (mysqlDialect) noLimit() string {
+	return "18446744073709551615"
+}
+
+func (mysqlDialect) inlinePrimaryKey() bool {
+	return false
+}
+
+func (mysqlDialect) inlineIndex() bool {
+	return true
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string {
+	return "sqlite3"
+}
+
+func (sqliteDialect) columnType(typ reflect.Type, size int) (string, error) {
+	typ = baseType(typ)
+	if typ == timeType {
+		return "DATETIME", nil
+	}
+	switch typ.Kind() {
+	case reflect.Bool, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int, reflect.Int64,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint, reflect.Uint64:
+		return "INTEGER", nil
+	case reflect.Float32, reflect.Float64:
+		return "REAL", nil
+	case reflect.String:
+		return "TEXT", nil
+	case reflect.Slice:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return "BLOB", nil
+		}
+	}
+	return "", fmt.Errorf("toy-orm: 不支持的列类型 %s", typ)
+}
+
+func (sqliteDialect) autoIncrement() string {
+	return "AUTOINCREMENT"
+}
+
+func (sqliteDialect) noLimit() string {
+	return "-1"
+}
+
+// inlinePrimaryKey SQLite 的自增列必须是 INTEGER PRIMARY KEY
+func (sqliteDialect) inlinePrimaryKey() bool {
+	return true
+}
+
+func (sqliteDialect) inlineIndex() bool {
+	return false
+}
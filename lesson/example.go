@@ -0,0 +1,115 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+)
+
+// ExampleOption 控制 WhereExample 怎么把字段转换为条件
+type ExampleOption func(opt *exampleOptions)
+
+type exampleOptions struct {
+	// zeros 是零值也要作为条件的字段
+	zeros []string
+	// like 为 true 的时候字符串字段使用 LIKE，likeFields 为空表示所有的字符串字段
+	like       bool
+	likeFields []string
+}
+
+// ExampleWithZero 指定的字段即便是零值也作为条件，例如查询 Age = 0 的数据
+func ExampleWithZero(fields ...string) ExampleOption {
+	return func(opt *exampleOptions) {
+		opt.zeros = append(opt.zeros, fields...)
+	}
+}
+
+// ExampleLike 字符串字段使用 LIKE '%值%'，不指定字段的时候对所有的字符串字段生效。
+// 空字符串仍然使用相等条件，值里面的 % 和 _ 不会被转义
+func ExampleLike(fields ...string) ExampleOption {
+	return func(opt *exampleOptions) {
+		opt.like = true
+		opt.likeFields = append(opt.likeFields, fields...)
+	}
+}
+
+// examplePredicates 按照字段的声明顺序把 val 的字段转换为条件
+func examplePredicates(mi *ModelInfo, val reflect.Value, opt exampleOptions) ([]Predicate, error) {
+	zeros := make(map[string]bool, len(opt.zeros))
+	for _, name := range opt.zeros {
+		if _, ok := mi.fieldMap[name]; !ok {
+			return nil, fmt.Errorf("toy-orm: 非法列名 %s", name)
+		}
+		zeros[name] = true
+	}
+	likes := make(map[string]bool, len(opt.likeFields))
+	for _, name := range opt.likeFields {
+		fi, ok := mi.fieldMap[name]
+		if !ok {
+			return nil, fmt.Errorf("toy-orm: 非法列名 %s", name)
+		}
+		if fi.typ.Kind() != reflect.String {
+			return nil, fmt.Errorf("toy-orm: 字段 %s 不是字符串，不能使用 LIKE", name)
+		}
+		likes[name] = true
+	}
+
+	res := make([]Predicate, 0, len(mi.fields))
+	for _, name := range mi.fields {
+		fv := fieldValue(val, name)
+		rv := reflect.ValueOf(fv)
+		if !zeros[name] && (!rv.IsValid() || rv.IsZero()) {
+			continue
+		}
+		fi := mi.fieldMap[name]
+		if opt.like && fi.typ.Kind() == reflect.String && rv.String() != "" && (len(likes) == 0 || likes[name]) {
+			res = append(res, C(name).Like("%"+rv.String()+"%"))
+			continue
+		}
+		// = NULL 永远不成立，nil 指针和 Valid 为 false 的 sql.NullXXX 使用 IS NULL
+		if isNullValue(fv) {
+			res = append(res, C(name).IsNull())
+			continue
+		}
+		res = append(res, C(name).EQ(fv))
+	}
+	return res, nil
+}
+
+// isNullValue 判断 val 写入数据库之后是不是 NULL，
+// 也就是 nil、nil 指针或者 Value 返回 nil 的 driver.Valuer
+func isNullValue(val any) bool {
+	rv := reflect.ValueOf(val)
+	for rv.IsValid() {
+		if rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return true
+		}
+		if v, ok := rv.Interface().(driver.Valuer); ok {
+			dv, err := v.Value()
+			if err != nil {
+				return false
+			}
+			rv = reflect.ValueOf(dv)
+			continue
+		}
+		if rv.Kind() != reflect.Ptr {
+			return false
+		}
+		rv = rv.Elem()
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import "context"
+
+// 模型可以选择性实现下面的接口，来设置默认值或者校验自身。
+// 钩子拿到的 sess 就是执行当前语句的 Session，
+// 所以在事务里面执行的时候，钩子发起的查询也在同一个事务里面。
+// 任何一个钩子返回 error，都会中断整个操作。
+
+// BeforeInserter 在 INSERT 语句构造之前调用
+type BeforeInserter interface {
+	BeforeInsert(ctx context.Context, sess Session) error
+}
+
+// AfterInserter 在 INSERT 语句执行成功之后调用
+type AfterInserter interface {
+	AfterInsert(ctx context.Context, sess Session) error
+}
+
+// BeforeUpdater 在 UPDATE 语句构造之前调用
+type BeforeUpdater interface {
+	BeforeUpdate(ctx context.Context, sess Session) error
+}
+
+// AfterSelecter 在查询结果映射到结构体之后调用。
+// 使用 Iter 遍历的时候结果集还没有关闭，钩子里面不能发起查询
+type AfterSelecter interface {
+	AfterSelect(ctx context.Context, sess Session) error
+}
+
+func beforeInsert(ctx context.Context, sess Session, val any) error {
+	if h, ok := val.(BeforeInserter); ok {
+		return h.BeforeInsert(ctx, sess)
+	}
+	return nil
+}
+
+func afterInsert(ctx context.Context, sess Session, val any) error {
+	if h, ok := val.(AfterInserter); ok {
+		return h.AfterInsert(ctx, sess)
+	}
+	return nil
+}
+
+func beforeUpdate(ctx context.Context, sess Session, val any) error {
+	if h, ok := val.(BeforeUpdater); ok {
+		return h.BeforeUpdate(ctx, sess)
+	}
+	return nil
+}
+
+func afterSelect(ctx context.Context, sess Session, val any) error {
+	if h, ok := val.(AfterSelecter); ok {
+		return h.AfterSelect(ctx, sess)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// HookModel 在钩子里面设置默认值和校验
+type HookModel struct {
+	Id        int64
+	FirstName string
+	Status    string
+}
+
+func (h *HookModel) BeforeInsert(ctx context.Context, sess Session) error {
+	if h.FirstName == "" {
+		return errors.New("first name is required")
+	}
+	if h.Status == "" {
+		h.Status = "active"
+	}
+	return nil
+}
+
+func (h *HookModel) AfterInsert(ctx context.Context, sess Session) error {
+	// 在同一个 Session 上发起后续的语句
+	_, err := NewUpdater[HookModel](sess).Set(Assign("Status", "inserted")).
+		Where(C("FirstName").EQ(h.FirstName)).Exec(ctx).RowsAffected()
+	return err
+}
+
+func (h *HookModel) BeforeUpdate(ctx context.Context, sess Session) error {
+	if h.Id <= 0 {
+		return errors.New("id is required")
+	}
+	return nil
+}
+
+func (h *HookModel) AfterSelect(ctx context.Context, sess Session) error {
+	if h.Status == "banned" {
+		return errors.New("banned")
+	}
+	h.FirstName = "Mr. " + h.FirstName
+	return nil
+}
+
+func TestHook_Insert(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// BeforeInsert 返回错误，不会执行任何语句
+	res := NewInserter[HookModel](db).Values(&HookModel{Id: 1}).Exec(context.Background())
+	_, err = res.RowsAffected()
+	assert.Equal(t, errors.New("first name is required"), err)
+
+	// BeforeInsert 设置默认值，AfterInsert 在事务里面执行后续语句
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `hook_model`").
+		WithArgs(int64(1), "Tom", "active").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE `hook_model` SET `status`=\\? WHERE `first_name` = \\?;").
+		WithArgs("inserted", "Tom").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	tx, err := db.Begin(context.Background(), &sql.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hm := &HookModel{Id: 1, FirstName: "Tom"}
+	res = NewInserter[HookModel](tx).Values(hm).Exec(context.Background())
+	affected, err := res.RowsAffected()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(1), affected)
+	assert.Equal(t, "active", hm.Status)
+	assert.Nil(t, tx.Commit())
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestHook_Update(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := NewUpdater[HookModel](db).Update(&HookModel{FirstName: "Tom"}).Exec(context.Background())
+	_, err = res.RowsAffected()
+	assert.Equal(t, errors.New("id is required"), err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestHook_Select(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := sqlmock.NewRows([]string{"id", "first_name", "status"})
+	rows.AddRow([]byte("1"), []byte("Tom"), []byte("active"))
+	mock.ExpectQuery("SELECT .*").WillReturnRows(rows)
+	hm, err := NewSelector[HookModel](db).Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Mr. Tom", hm.FirstName)
+
+	rows = sqlmock.NewRows([]string{"id", "first_name", "status"})
+	rows.AddRow([]byte("1"), []byte("Tom"), []byte("active"))
+	rows.AddRow([]byte("2"), []byte("Jerry"), []byte("active"))
+	mock.ExpectQuery("SELECT .*").WillReturnRows(rows)
+	hms, err := NewSelector[HookModel](db).GetMulti(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []*HookModel{
+		{Id: 1, FirstName: "Mr. Tom", Status: "active"},
+		{Id: 2, FirstName: "Mr. Jerry", Status: "active"},
+	}, hms)
+
+	// AfterSelect 返回错误会中断查询
+	rows = sqlmock.NewRows([]string{"id", "first_name", "status"})
+	rows.AddRow([]byte("3"), []byte("Spike"), []byte("banned"))
+	mock.ExpectQuery("SELECT .*").WillReturnRows(rows)
+	_, err = NewSelector[HookModel](db).GetMulti(context.Background())
+	assert.Equal(t, errors.New("banned"), err)
+}
@@ -20,26 +20,61 @@ import (
 	"errors"
 	"reflect"
 	"strings"
+	"time"
 )
 
 type Inserter[T any] struct {
 	sess   Session
 	values []*T
+	// tbl 不为空的时候代替模型的表名，用于分表
+	tbl string
 }
 
 func (i *Inserter[T]) Build() (*Query, error) {
+	return i.build(context.Background())
+}
+
+func (i *Inserter[T]) build(ctx context.Context) (*Query, error) {
 	if len(i.values) == 0 {
 		return &Query{}, errors.New("toy-orm: 插入0行")
 	}
-	meta, err := i.sess.registry().get(i.values[0])
+	c := i.sess.getCore()
+	meta, err := c.r.get(i.values[0])
 	if err != nil {
 		return nil, err
 	}
+	if meta.createTime != nil || meta.updateTime != nil {
+		now := c.clock()
+		for _, val := range i.values {
+			refVal := reflect.ValueOf(val).Elem()
+			fillTimestamp(refVal, meta.createTime, now)
+			fillTimestamp(refVal, meta.updateTime, now)
+		}
+	}
+	for _, val := range i.values {
+		if err = c.fillTenant(ctx, reflect.ValueOf(val).Elem(), meta); err != nil {
+			return nil, err
+		}
+	}
+	if ver := meta.version; ver != nil {
+		// 版本号从 1 开始
+		for _, val := range i.values {
+			fd := reflect.ValueOf(val).Elem().FieldByName(ver.fieldName)
+			if fd.IsZero() {
+				fd.Set(reflect.ValueOf(1).Convert(ver.typ))
+			}
+		}
+	}
+	fields := insertFields(meta, i.values)
 	var sb strings.Builder
+	tbl := meta.tableName
+	if i.tbl != "" {
+		tbl = i.tbl
+	}
 	sb.WriteString("INSERT INTO `")
-	sb.WriteString(meta.tableName)
+	sb.WriteString(tbl)
 	sb.WriteString("`(")
-	for index, fd := range meta.fields {
+	for index, fd := range fields {
 		if index > 0 {
 			sb.WriteByte(',')
 		}
@@ -50,20 +85,19 @@ func (i *Inserter[T]) Build() (*Query, error) {
 	}
 	sb.WriteString(")")
 	sb.WriteString(" VALUES")
-	args := make([]any, 0, len(i.values)*len(meta.fields))
+	args := make([]any, 0, len(i.values)*len(fields))
 	for index, val := range i.values {
 		if index > 0 {
 			sb.WriteByte(',')
 		}
 		sb.WriteByte('(')
 		refVal := reflect.ValueOf(val).Elem()
-		for j, v := range meta.fields {
+		for j, v := range fields {
 			if j > 0 {
 				sb.WriteByte(',')
 			}
-			fdVal := refVal.FieldByName(v)
 			sb.WriteByte('?')
-			args = append(args, fdVal.Interface())
+			args = append(args, fieldValue(refVal, v))
 		}
 		sb.WriteByte(')')
 	}
@@ -72,19 +106,73 @@ func (i *Inserter[T]) Build() (*Query, error) {
 }
 
 func (i *Inserter[T]) Exec(ctx context.Context) sql.Result {
-	q, err := i.Build()
+	for _, val := range i.values {
+		if err := beforeInsert(ctx, i.sess, val); err != nil {
+			return Result{err: err}
+		}
+	}
+	q, err := i.build(ctx)
 	if err != nil {
 		return Result{
 			err: err,
 		}
 	}
 	res, err := i.sess.exec(ctx, q.SQL, q.Args...)
+	invalidateCache(ctx, i.sess, i.table())
+	if err != nil {
+		return Result{err: err}
+	}
+	for _, val := range i.values {
+		if err = afterInsert(ctx, i.sess, val); err != nil {
+			return Result{err: err, res: res}
+		}
+	}
 	return Result{
-		err: err,
 		res: res,
 	}
 }
 
+// table 返回插入的表名，只能在 build 成功之后调用
+func (i *Inserter[T]) table() string {
+	if i.tbl != "" {
+		return i.tbl
+	}
+	meta, _ := i.sess.getCore().r.get(i.values[0])
+	return meta.tableName
+}
+
+// insertFields 返回需要插入的字段。自增字段在所有的值里面都是零值的时候会被跳过，由数据库生成
+func insertFields[T any](meta *ModelInfo, vals []*T) []string {
+	fields := meta.fields
+	for _, fd := range meta.fields {
+		fi := meta.fieldMap[fd]
+		if !fi.autoIncrement {
+			continue
+		}
+		zero := true
+		for _, val := range vals {
+			if !reflect.ValueOf(val).Elem().FieldByName(fi.fieldName).IsZero() {
+				zero = false
+				break
+			}
+		}
+		if zero {
+			fields = withoutField(fields, fd)
+		}
+	}
+	return fields
+}
+
+func withoutField(fields []string, name string) []string {
+	res := make([]string, 0, len(fields))
+	for _, fd := range fields {
+		if fd != name {
+			res = append(res, fd)
+		}
+	}
+	return res
+}
+
 func NewInserter[T any](sess Session) *Inserter[T] {
 	return &Inserter[T]{sess: sess}
 }
@@ -93,3 +181,15 @@ func (i *Inserter[T]) Values(vals ...*T) *Inserter[T] {
 	i.values = vals
 	return i
 }
+
+// fillTimestamp 在字段为零值的时候设置时间戳，用户自己设置的值不会被覆盖
+func fillTimestamp(val reflect.Value, fi *FieldInfo, now time.Time) {
+	if fi == nil {
+		return
+	}
+	fd := val.FieldByName(fi.fieldName)
+	if fd.IsZero() {
+		// 字段可能是 int64 的自定义类型，例如 type Millis int64
+		fd.Set(reflect.ValueOf(fi.timestamp(now)).Convert(fi.typ))
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestInserter_Build(t *testing.T) {
@@ -28,6 +29,10 @@ func TestInserter_Build(t *testing.T) {
 		FirstName string
 		Ctime     uint64
 	}
+	type AutoUser struct {
+		Id   int64 `orm:"pk;autoIncrement"`
+		Name string
+	}
 	n := uint64(1000)
 	u := &User{
 		Id:        12,
@@ -73,6 +78,19 @@ func TestInserter_Build(t *testing.T) {
 			wantSql:  "INSERT INTO `user`(`id`,`first_name`,`ctime`) VALUES(?,?,?),(?,?,?);",
 			wantArgs: []interface{}{int64(12), "Tom", n, int64(13), "Jerry", n},
 		},
+		{
+			// 自增字段都是零值的时候由数据库生成
+			name:     "zero auto increment",
+			builder:  NewInserter[AutoUser](db).Values(&AutoUser{Name: "Tom"}, &AutoUser{Name: "Jerry"}),
+			wantSql:  "INSERT INTO `auto_user`(`name`) VALUES(?),(?);",
+			wantArgs: []interface{}{"Tom", "Jerry"},
+		},
+		{
+			name:     "partial auto increment",
+			builder:  NewInserter[AutoUser](db).Values(&AutoUser{Name: "Tom"}, &AutoUser{Id: 2, Name: "Jerry"}),
+			wantSql:  "INSERT INTO `auto_user`(`id`,`name`) VALUES(?,?),(?,?);",
+			wantArgs: []interface{}{int64(0), "Tom", int64(2), "Jerry"},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -111,3 +129,75 @@ func TestInserter_Exec(t *testing.T) {
 	}
 	assert.True(t, id > 0)
 }
+
+func TestInserter_Timestamp(t *testing.T) {
+	type Order struct {
+		Id         int64
+		CreateTime int64
+		UpdateTime time.Time
+	}
+	now := time.UnixMilli(1655000000000)
+	mockDB, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB, DBWithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 零值会被自动填充
+	o := &Order{Id: 1}
+	q, err := NewInserter[Order](db).Values(o).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []any{int64(1), int64(1655000000000), now}, q.Args)
+	assert.Equal(t, &Order{Id: 1, CreateTime: 1655000000000, UpdateTime: now}, o)
+
+	// 用户设置的值不会被覆盖
+	o = &Order{Id: 2, CreateTime: 123}
+	q, err = NewInserter[Order](db).Values(o).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []any{int64(2), int64(123), now}, q.Args)
+
+	// int64 的自定义类型
+	type MillisOrder struct {
+		Id         int64
+		CreateTime Millis
+	}
+	mo := &MillisOrder{Id: 3}
+	q, err = NewInserter[MillisOrder](db).Values(mo).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []any{int64(3), Millis(1655000000000)}, q.Args)
+	assert.Equal(t, Millis(1655000000000), mo.CreateTime)
+}
+
+// Millis 是毫秒时间戳，用于测试 int64 的自定义类型
+type Millis int64
+
+func TestInserter_Version(t *testing.T) {
+	mockDB, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vm := &VersionModel{Id: 1, FirstName: "Tom"}
+	q, err := NewInserter[VersionModel](db).Values(vm).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "INSERT INTO `version_model`(`id`,`first_name`,`version`) VALUES(?,?,?);", q.SQL)
+	assert.Equal(t, []any{int64(1), "Tom", int64(1)}, q.Args)
+	assert.Equal(t, int64(1), vm.Version)
+}
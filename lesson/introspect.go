@@ -0,0 +1,249 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var ErrTableNotFound = errors.New("toy-orm: 表不存在")
+
+// TableSchema 是数据库里面实际的表结构
+type TableSchema struct {
+	Name    string
+	Columns []ColumnSchema
+	// Indexes 不包含主键
+	Indexes []IndexSchema
+}
+
+type ColumnSchema struct {
+	Name string
+	// Type 是数据库返回的列类型，例如 bigint unsigned
+	Type    string
+	NotNull bool
+	PK      bool
+	Default sql.NullString
+}
+
+type IndexSchema struct {
+	Name    string
+	Unique  bool
+	Columns []string
+}
+
+// TableSchema 读取表结构，表不存在的时候返回 ErrTableNotFound
+func (db *DB) TableSchema(ctx context.Context, table string) (*TableSchema, error) {
+	return db.dialect.tableSchema(ctx, db, table)
+}
+
+// Tables 返回当前数据库里面所有的表，按照表名排序
+func (db *DB) Tables(ctx context.Context) ([]string, error) {
+	return db.dialect.tables(ctx, db)
+}
+
+func queryStrings(ctx context.Context, sess Session, query string) ([]string, error) {
+	rows, err := sess.query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	var res []string
+	for rows.Next() {
+		var s string
+		if err = rows.Scan(&s); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		res = append(res, s)
+	}
+	return res, closeRows(rows)
+}
+
+func (mysqlDialect) tables(ctx context.Context, sess Session) ([]string, error) {
+	return queryStrings(ctx, sess, "SELECT `TABLE_NAME` FROM `information_schema`.`TABLES` "+
+		"WHERE `TABLE_SCHEMA` = DATABASE() AND `TABLE_TYPE` = 'BASE TABLE' ORDER BY `TABLE_NAME`;")
+}
+
+func (mysqlDialect) tableSchema(ctx context.Context, sess Session, table string) (*TableSchema, error) {
+	rows, err := sess.query(ctx, "SELECT `COLUMN_NAME`,`COLUMN_TYPE`,`IS_NULLABLE`,`COLUMN_KEY`,`COLUMN_DEFAULT` "+
+		"FROM `information_schema`.`COLUMNS` WHERE `TABLE_SCHEMA` = DATABASE() AND `TABLE_NAME` = ? "+
+		"ORDER BY `ORDINAL_POSITION`;", table)
+	if err != nil {
+		return nil, err
+	}
+	res := &TableSchema{Name: table}
+	for rows.Next() {
+		var (
+			c             ColumnSchema
+			nullable, key string
+		)
+		if err = rows.Scan(&c.Name, &c.Type, &nullable, &key, &c.Default); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		c.NotNull = nullable == "NO"
+		c.PK = key == "PRI"
+		res.Columns = append(res.Columns, c)
+	}
+	if err = closeRows(rows); err != nil {
+		return nil, err
+	}
+	if len(res.Columns) == 0 {
+		return nil, ErrTableNotFound
+	}
+
+	rows, err = sess.query(ctx, "SELECT `INDEX_NAME`,`NON_UNIQUE`,`COLUMN_NAME` "+
+		"FROM `information_schema`.`STATISTICS` WHERE `TABLE_SCHEMA` = DATABASE() AND `TABLE_NAME` = ? "+
+		"ORDER BY `INDEX_NAME`,`SEQ_IN_INDEX`;", table)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var (
+			name, col string
+			nonUnique bool
+		)
+		if err = rows.Scan(&name, &nonUnique, &col); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		if name == "PRIMARY" {
+			continue
+		}
+		if n := len(res.Indexes); n > 0 && res.Indexes[n-1].Name == name {
+			res.Indexes[n-1].Columns = append(res.Indexes[n-1].Columns, col)
+			continue
+		}
+		res.Indexes = append(res.Indexes, IndexSchema{Name: name, Unique: !nonUnique, Columns: []string{col}})
+	}
+	return res, closeRows(rows)
+}
+
+var intDisplayWidth = regexp.MustCompile(`^(TINYINT|SMALLINT|MEDIUMINT|INT|BIGINT)\(\d+\)`)
+
+// normalizeType MySQL 8.0 之前的版本会返回整数的显示宽度，例如 int(11)，
+// 但是 TINYINT(1) 代表 bool，需要保留
+func (mysqlDialect) normalizeType(typ string) string {
+	typ = strings.ToUpper(typ)
+	if strings.HasPrefix(typ, "TINYINT(1)") {
+		return typ
+	}
+	return intDisplayWidth.ReplaceAllString(typ, "$1")
+}
+
+func (sqliteDialect) tables(ctx context.Context, sess Session) ([]string, error) {
+	return queryStrings(ctx, sess, "SELECT `name` FROM `sqlite_master` "+
+		"WHERE `type` = 'table' AND `name` NOT LIKE 'sqlite_%' ORDER BY `name`;")
+}
+
+func (sqliteDialect) tableSchema(ctx context.Context, sess Session, table string) (*TableSchema, error) {
+	b := &builder{}
+	b.sb.WriteString("PRAGMA table_info(")
+	b.quote(table)
+	b.sb.WriteString(");")
+	rows, err := sess.query(ctx, b.sb.String())
+	if err != nil {
+		return nil, err
+	}
+	res := &TableSchema{Name: table}
+	for rows.Next() {
+		var (
+			c       ColumnSchema
+			cid, pk int
+		)
+		if err = rows.Scan(&cid, &c.Name, &c.Type, &c.NotNull, &c.Default, &pk); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		c.PK = pk > 0
+		res.Columns = append(res.Columns, c)
+	}
+	if err = closeRows(rows); err != nil {
+		return nil, err
+	}
+	if len(res.Columns) == 0 {
+		return nil, ErrTableNotFound
+	}
+
+	b.reset()
+	b.sb.WriteString("PRAGMA index_list(")
+	b.quote(table)
+	b.sb.WriteString(");")
+	rows, err = sess.query(ctx, b.sb.String())
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var (
+			seq, partial int
+			idx          IndexSchema
+			origin       string
+		)
+		if err = rows.Scan(&seq, &idx.Name, &idx.Unique, &origin, &partial); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		// origin 为 pk 或者 u 的是 SQLite 为主键和 UNIQUE 约束自动创建的索引
+		if origin == "c" {
+			res.Indexes = append(res.Indexes, idx)
+		}
+	}
+	if err = closeRows(rows); err != nil {
+		return nil, err
+	}
+
+	// index_list 的结果集关闭之后再查询每个索引的列
+	for i := range res.Indexes {
+		b.reset()
+		b.sb.WriteString("PRAGMA index_info(")
+		b.quote(res.Indexes[i].Name)
+		b.sb.WriteString(");")
+		rows, err = sess.query(ctx, b.sb.String())
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var (
+				seqno, cid int
+				col        string
+			)
+			if err = rows.Scan(&seqno, &cid, &col); err != nil {
+				_ = rows.Close()
+				return nil, err
+			}
+			res.Indexes[i].Columns = append(res.Indexes[i].Columns, col)
+		}
+		if err = closeRows(rows); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (sqliteDialect) normalizeType(typ string) string {
+	return strings.ToUpper(typ)
+}
+
+// closeRows 关闭结果集，并且返回遍历过程中的错误
+func closeRows(rows *sql.Rows) error {
+	if err := rows.Err(); err != nil {
+		_ = rows.Close()
+		return err
+	}
+	return rows.Close()
+}
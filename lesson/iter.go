@@ -0,0 +1,107 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+)
+
+// Cursor 逐行读取结果集，内存占用和结果集的大小无关。用法和 sql.Rows 一样：
+//
+//	cur := NewSelector[User](db).Iter(ctx)
+//	defer cur.Close()
+//	for cur.Next() {
+//		u, err := cur.Scan()
+//	}
+//	err := cur.Err()
+//
+// 遍历的过程中会一直占用一个连接，所以不支持 Preload。
+// AfterSelect 钩子在连接还没有释放的时候执行，钩子里面不能发起查询，
+// 否则在只有一个连接的连接池或者事务里面会阻塞或者失败
+type Cursor[T any] struct {
+	ctx  context.Context
+	sess Session
+	mi   *ModelInfo
+	rows *sql.Rows
+	// fds 在第一次 Scan 的时候计算，之后的每一行都复用
+	fds []*FieldInfo
+	err error
+}
+
+// Iter 执行查询并返回 Cursor，查询失败的时候 Next 返回 false，Err 返回错误
+func (s *Selector[T]) Iter(ctx context.Context) *Cursor[T] {
+	c := &Cursor[T]{ctx: ctx, sess: s.sess}
+	if len(s.preloads) > 0 {
+		c.err = errors.New("toy-orm: Iter 不支持 Preload")
+		return c
+	}
+	q, err := s.build(ctx)
+	if err != nil {
+		c.err = err
+		return c
+	}
+	c.mi = s.mi
+	c.rows, c.err = s.sess.query(ctx, q.SQL, q.Args...)
+	return c
+}
+
+func (c *Cursor[T]) Next() bool {
+	if c.err != nil || c.rows == nil {
+		return false
+	}
+	return c.rows.Next()
+}
+
+// Scan 读取当前行，只能在 Next 返回 true 之后调用
+func (c *Cursor[T]) Scan() (*T, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	if c.fds == nil {
+		if c.fds, c.err = columnFields(c.rows, c.mi); c.err != nil {
+			return nil, c.err
+		}
+	}
+	tp := new(T)
+	if err := c.sess.getCore().scanFields(c.rows, c.fds, reflect.ValueOf(tp).Elem()); err != nil {
+		return nil, err
+	}
+	if err := afterSelect(c.ctx, c.sess, tp); err != nil {
+		return nil, err
+	}
+	return tp, nil
+}
+
+// Err 返回查询或者遍历过程中的错误
+func (c *Cursor[T]) Err() error {
+	if c.err != nil {
+		return c.err
+	}
+	if c.rows == nil {
+		return nil
+	}
+	return c.rows.Err()
+}
+
+// Close 释放连接，可以多次调用
+func (c *Cursor[T]) Close() error {
+	if c.rows == nil {
+		return nil
+	}
+	return c.rows.Close()
+}
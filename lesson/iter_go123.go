@@ -0,0 +1,46 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.23
+
+package lesson
+
+import (
+	"context"
+	"iter"
+)
+
+// All 返回可以用 range 遍历的迭代器，提前 break 的时候会自动关闭结果集：
+//
+//	for u, err := range NewSelector[User](db).All(ctx) {
+//		if err != nil {
+//			return err
+//		}
+//	}
+//
+// 查询或者遍历出错的时候，最后一次迭代返回错误
+func (s *Selector[T]) All(ctx context.Context) iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		cur := s.Iter(ctx)
+		defer func() { _ = cur.Close() }()
+		for cur.Next() {
+			if !yield(cur.Scan()) {
+				return
+			}
+		}
+		if err := cur.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
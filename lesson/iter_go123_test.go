@@ -0,0 +1,59 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.23
+
+package lesson
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSelector_All(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	mock.ExpectQuery("SELECT .*").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2).AddRow(3)).
+		RowsWillBeClosed()
+	var ids []int64
+	for tm, err := range NewSelector[TestModel](db).All(ctx) {
+		assert.Nil(t, err)
+		ids = append(ids, tm.Id)
+		// 提前退出的时候也会关闭结果集
+		if tm.Id == 2 {
+			break
+		}
+	}
+	assert.Equal(t, []int64{1, 2}, ids)
+
+	mock.ExpectQuery("SELECT .*").WillReturnError(errors.New("query error"))
+	for tm, err := range NewSelector[TestModel](db).All(ctx) {
+		assert.Nil(t, tm)
+		assert.Equal(t, errors.New("query error"), err)
+	}
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,112 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSelector_Iter(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name     string
+		mockRows *sqlmock.Rows
+		mockErr  error
+		s        *Selector[TestModel]
+		wantRes  []*TestModel
+		wantErr  error
+	}{
+		{
+			name: "rows",
+			mockRows: sqlmock.NewRows([]string{"id", "first_name"}).
+				AddRow(1, "Tom").AddRow(2, "Jerry"),
+			s:       NewSelector[TestModel](db),
+			wantRes: []*TestModel{{Id: 1, FirstName: "Tom"}, {Id: 2, FirstName: "Jerry"}},
+		},
+		{
+			// 遍历到一半出错
+			name: "row error",
+			mockRows: sqlmock.NewRows([]string{"id"}).
+				AddRow(1).AddRow(2).RowError(1, errors.New("broken")),
+			s:       NewSelector[TestModel](db),
+			wantRes: []*TestModel{{Id: 1}},
+			wantErr: errors.New("broken"),
+		},
+		{
+			name:    "query error",
+			mockErr: errors.New("query error"),
+			s:       NewSelector[TestModel](db),
+			wantErr: errors.New("query error"),
+		},
+		{
+			// 构造 SQL 失败的时候不会发起查询
+			name:    "build error",
+			s:       NewSelector[TestModel](db).Where(C("Invalid").EQ(1)),
+			wantErr: errors.New("toy-orm: 非法列名 Invalid"),
+		},
+		{
+			name:    "preload",
+			s:       NewSelector[TestModel](db).Preload("Orders"),
+			wantErr: errors.New("toy-orm: Iter 不支持 Preload"),
+		},
+		{
+			name:     "invalid column",
+			mockRows: sqlmock.NewRows([]string{"invalid"}).AddRow(1),
+			s:        NewSelector[TestModel](db),
+			wantErr:  errors.New("toy-orm: 非法列名 invalid"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.mockErr != nil {
+				mock.ExpectQuery("SELECT .*").WillReturnError(tc.mockErr)
+			} else if tc.mockRows != nil {
+				mock.ExpectQuery("SELECT .*").WillReturnRows(tc.mockRows).RowsWillBeClosed()
+			}
+			cur := tc.s.Iter(context.Background())
+			var (
+				res []*TestModel
+				err error
+			)
+			for cur.Next() {
+				var tm *TestModel
+				if tm, err = cur.Scan(); err != nil {
+					break
+				}
+				res = append(res, tm)
+			}
+			if err == nil {
+				err = cur.Err()
+			}
+			assert.Nil(t, cur.Close())
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantRes, res)
+			assert.Nil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
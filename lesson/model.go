@@ -15,9 +15,14 @@
 package lesson
 
 import (
+	"database/sql"
 	"errors"
+	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 	"unicode"
 )
 
@@ -26,12 +31,88 @@ type ModelInfo struct {
 	fields    []string
 	fieldMap  map[string]*FieldInfo
 	columnMap map[string]*FieldInfo
+
+	// createTime 和 updateTime 是由 ORM 自动维护的时间戳字段，可能为 nil
+	createTime *FieldInfo
+	updateTime *FieldInfo
+	// softDelete 是软删除字段，可能为 nil
+	softDelete *FieldInfo
+	// version 是乐观锁的版本号字段，可能为 nil
+	version *FieldInfo
+	// relations 是关联字段，key 是字段名
+	relations map[string]*relation
+
+	// pks 是主键，没有 pk 标签的时候默认是 Id 字段
+	pks []*FieldInfo
+	// indexes 是 index 和 unique 标签声明的索引，按照声明的顺序排列
+	indexes []*index
+}
+
+// index 是一个索引，同名的索引会合并为联合索引
+type index struct {
+	name    string
+	unique  bool
+	columns []string
 }
 
 type FieldInfo struct {
 	columnName string
 	fieldName  string
 	typ        reflect.Type
+	// precision 是 int64 类型时间戳的精度，time.Millisecond 或者 time.Second
+	precision time.Duration
+	// offset 是字段相对于结构体起始地址的偏移量
+	offset uintptr
+
+	// 以下是建表用的信息
+	autoIncrement bool
+	notNull       bool
+	// dflt 是默认值，原样拼接在 DEFAULT 后面
+	dflt *string
+	// size 是字符串或者二进制的长度
+	size int
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	timePtrType   = reflect.TypeOf(&time.Time{})
+	int64PtrType  = reflect.TypeOf(new(int64))
+	nullTimeType  = reflect.TypeOf(sql.NullTime{})
+	nullInt64Type = reflect.TypeOf(sql.NullInt64{})
+)
+
+// timestamp 根据字段类型把 now 转换为对应的值
+func (f *FieldInfo) timestamp(now time.Time) any {
+	if f.typ == timeType {
+		return now
+	}
+	if f.precision == time.Second {
+		return now.Unix()
+	}
+	return now.UnixMilli()
+}
+
+// deletedAt 根据软删除字段的类型把 now 转换为对应的值
+func (f *FieldInfo) deletedAt(now time.Time) any {
+	unix := now.UnixMilli()
+	if f.precision == time.Second {
+		unix = now.Unix()
+	}
+	switch f.typ {
+	case timePtrType:
+		return &now
+	case nullTimeType:
+		return sql.NullTime{Time: now, Valid: true}
+	case int64PtrType:
+		return &unix
+	default:
+		return sql.NullInt64{Int64: unix, Valid: true}
+	}
+}
+
+// TableNamer 用于指定表名，默认的表名是类型名转换为下划线命名
+type TableNamer interface {
+	TableName() string
 }
 
 type registry struct {
@@ -45,31 +126,68 @@ func (r *registry) register(val any) (*ModelInfo, error) {
 		return nil, errors.New("toy-orm: 非法类型")
 	}
 	typ = typ.Elem()
+	tableName := underscoreName(typ.Name())
+	if tn, ok := val.(TableNamer); ok {
+		tableName = tn.TableName()
+	}
 
 	numField := typ.NumField()
 	fdInfos := make(map[string]*FieldInfo, numField)
-	fds := make([]string, numField)
+	fds := make([]string, 0, numField)
 	cm := make(map[string]*FieldInfo, numField)
+	mi := &ModelInfo{
+		tableName: tableName,
+		fieldMap:  fdInfos,
+		columnMap: cm,
+	}
 	for i := 0; i < numField; i++ {
 		fd := typ.Field(i)
+		tags := parseTag(fd.Tag.Get("orm"))
+		// 关联字段不是列
+		rel, err := parseRelation(typ, fd, tags)
+		if err != nil {
+			return nil, err
+		}
+		if rel != nil {
+			if mi.relations == nil {
+				mi.relations = make(map[string]*relation, 2)
+			}
+			mi.relations[fd.Name] = rel
+			continue
+		}
+
 		fn := fd.Name
 		cn := underscoreName(fn)
+		if c := tags["column"]; c != "" {
+			cn = c
+		}
 		fi := &FieldInfo{
 			columnName: cn,
 			fieldName:  fn,
 			typ:        fd.Type,
+			offset:     fd.Offset,
+		}
+		if err := mi.parseTimestamp(fi, tags); err != nil {
+			return nil, err
+		}
+		if err := mi.parseSoftDelete(fi, tags); err != nil {
+			return nil, err
+		}
+		if err := mi.parseVersion(fi, tags); err != nil {
+			return nil, err
+		}
+		if err := mi.parseColumnDef(fi, tags); err != nil {
+			return nil, err
 		}
 		fdInfos[fn] = fi
 		cm[cn] = fi
-		fds[i] = fn
+		fds = append(fds, fn)
 	}
-
-	mi := &ModelInfo{
-		tableName: underscoreName(typ.Name()),
-		fields:    fds,
-		fieldMap:  fdInfos,
-		columnMap: cm,
+	mi.fields = fds
+	if id, ok := fdInfos["Id"]; ok && len(mi.pks) == 0 {
+		mi.pks = []*FieldInfo{id}
 	}
+
 	r.models.Store(reflect.TypeOf(val), mi)
 	return mi, nil
 }
@@ -80,9 +198,173 @@ func (r *registry) get(val any) (*ModelInfo, error) {
 	if ok {
 		return mi.(*ModelInfo), nil
 	}
+	if mi, ok = defaultRegistry.models.Load(typ); ok {
+		return mi.(*ModelInfo), nil
+	}
 	return r.register(val)
 }
 
+// defaultRegistry 保存通过 Register 预先注册的模型，所有的 DB 共享
+var defaultRegistry = &registry{}
+
+// Register 预先注册模型，这样在启动的时候就能发现模型定义的错误，
+// 一般由 toyorm-gen 生成的代码在 init 里面调用
+func Register(val any) error {
+	_, err := defaultRegistry.register(val)
+	return err
+}
+
+// MustRegister 和 Register 一样，但是出错的时候会 panic
+func MustRegister(val any) {
+	if err := Register(val); err != nil {
+		panic(err)
+	}
+}
+
+// parseTimestamp 识别自动维护的时间戳字段。
+// 可以用标签 `orm:"createTime"`、`orm:"updateTime=second"` 来声明，
+// 也可以直接把字段命名为 CreateTime 或者 UpdateTime。
+// int64 类型的时间戳默认是毫秒，标签值为 second 的时候是秒。
+func (m *ModelInfo) parseTimestamp(fi *FieldInfo, tags map[string]string) error {
+	var (
+		target    **FieldInfo
+		precision string
+	)
+	if p, ok := tags["createTime"]; ok || fi.fieldName == "CreateTime" {
+		target, precision = &m.createTime, p
+	} else if p, ok = tags["updateTime"]; ok || fi.fieldName == "UpdateTime" {
+		target, precision = &m.updateTime, p
+	} else {
+		return nil
+	}
+
+	switch {
+	case fi.typ == timeType:
+	case fi.typ.Kind() == reflect.Int64:
+		if err := fi.parsePrecision(precision); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("toy-orm: 时间戳字段 %s 只能是 int64 或者 time.Time", fi.fieldName)
+	}
+	*target = fi
+	return nil
+}
+
+// parseSoftDelete 识别软删除字段，例如 `orm:"softdelete"`。
+// 字段必须可以为 NULL，NULL 代表没有被删除。
+// *int64 和 sql.NullInt64 记录的是毫秒，标签值为 second 的时候是秒。
+func (m *ModelInfo) parseSoftDelete(fi *FieldInfo, tags map[string]string) error {
+	precision, ok := tags["softdelete"]
+	if !ok {
+		return nil
+	}
+	switch fi.typ {
+	case timePtrType, nullTimeType:
+	case int64PtrType, nullInt64Type:
+		if err := fi.parsePrecision(precision); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("toy-orm: 软删除字段 %s 只能是 *time.Time、sql.NullTime、*int64 或者 sql.NullInt64", fi.fieldName)
+	}
+	m.softDelete = fi
+	return nil
+}
+
+// parseVersion 识别乐观锁的版本号字段，例如 `orm:"version"`
+func (m *ModelInfo) parseVersion(fi *FieldInfo, tags map[string]string) error {
+	if _, ok := tags["version"]; !ok {
+		return nil
+	}
+	switch fi.typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		return fmt.Errorf("toy-orm: 版本号字段 %s 只能是整数", fi.fieldName)
+	}
+	if m.version != nil {
+		return fmt.Errorf("toy-orm: 版本号字段重复 %s 和 %s", m.version.fieldName, fi.fieldName)
+	}
+	m.version = fi
+	return nil
+}
+
+// parseColumnDef 解析建表用的标签，例如
+// `orm:"pk;autoIncrement"`、`orm:"notNull;size=64;default=0"`。
+// index 和 unique 不带值的时候是单列索引，带值的时候值就是索引名，
+// 同名的列组成联合索引
+func (m *ModelInfo) parseColumnDef(fi *FieldInfo, tags map[string]string) error {
+	if hasTag(tags, "pk") {
+		m.pks = append(m.pks, fi)
+	}
+	fi.autoIncrement = hasTag(tags, "autoIncrement")
+	fi.notNull = hasTag(tags, "notNull")
+	if d, ok := tags["default"]; ok {
+		fi.dflt = &d
+	}
+	if size, ok := tags["size"]; ok {
+		n, err := strconv.Atoi(size)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("toy-orm: 字段 %s 的 size 必须是正整数", fi.fieldName)
+		}
+		fi.size = n
+	}
+	if name, ok := tags["index"]; ok {
+		m.addIndex(name, "idx_", false, fi)
+	}
+	if name, ok := tags["unique"]; ok {
+		m.addIndex(name, "uk_", true, fi)
+	}
+	return nil
+}
+
+func (m *ModelInfo) addIndex(name, prefix string, unique bool, fi *FieldInfo) {
+	if name == "" {
+		name = prefix + m.tableName + "_" + fi.columnName
+	}
+	for _, idx := range m.indexes {
+		if idx.name == name {
+			idx.columns = append(idx.columns, fi.columnName)
+			return
+		}
+	}
+	m.indexes = append(m.indexes, &index{name: name, unique: unique, columns: []string{fi.columnName}})
+}
+
+// parsePrecision 解析整数时间戳的精度，默认是毫秒
+func (f *FieldInfo) parsePrecision(precision string) error {
+	switch precision {
+	case "", "milli":
+		f.precision = time.Millisecond
+	case "second":
+		f.precision = time.Second
+	default:
+		return fmt.Errorf("toy-orm: 字段 %s 非法的时间戳精度 %s", f.fieldName, precision)
+	}
+	return nil
+}
+
+// parseTag 解析 orm 标签，多个部分之间用分号分隔，
+// 每一部分要么是 key，要么是 key=value，例如 `orm:"updateTime=second"`
+func parseTag(tag string) map[string]string {
+	res := make(map[string]string, 4)
+	for _, seg := range strings.Split(tag, ";") {
+		seg = strings.TrimSpace(seg)
+		if seg == "" {
+			continue
+		}
+		kv := strings.SplitN(seg, "=", 2)
+		key := strings.TrimSpace(kv[0])
+		if len(kv) == 2 {
+			res[key] = strings.TrimSpace(kv[1])
+		} else {
+			res[key] = ""
+		}
+	}
+	return res
+}
+
 // underscoreName 驼峰转字符串命名
 func underscoreName(tableName string) string {
 	var buf []byte
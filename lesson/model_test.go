@@ -20,6 +20,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"reflect"
 	"testing"
+	"time"
+	"unsafe"
 )
 
 func Test_registry_register(t *testing.T) {
@@ -51,16 +53,19 @@ func Test_registry_register(t *testing.T) {
 						columnName: "first_name",
 						fieldName:  "FirstName",
 						typ:        reflect.TypeOf(""),
+						offset:     unsafe.Offsetof(TestModel{}.FirstName),
 					},
 					"Age": {
 						columnName: "age",
 						fieldName:  "Age",
 						typ:        reflect.TypeOf(int8(0)),
+						offset:     unsafe.Offsetof(TestModel{}.Age),
 					},
 					"LastName": {
 						columnName: "last_name",
 						fieldName:  "LastName",
 						typ:        reflect.TypeOf(&sql.NullString{}),
+						offset:     unsafe.Offsetof(TestModel{}.LastName),
 					},
 				},
 				columnMap: map[string]*FieldInfo{
@@ -73,16 +78,27 @@ func Test_registry_register(t *testing.T) {
 						columnName: "first_name",
 						fieldName:  "FirstName",
 						typ:        reflect.TypeOf(""),
+						offset:     unsafe.Offsetof(TestModel{}.FirstName),
 					},
 					"age": {
 						columnName: "age",
 						fieldName:  "Age",
 						typ:        reflect.TypeOf(int8(0)),
+						offset:     unsafe.Offsetof(TestModel{}.Age),
 					},
 					"last_name": {
 						columnName: "last_name",
 						fieldName:  "LastName",
 						typ:        reflect.TypeOf(&sql.NullString{}),
+						offset:     unsafe.Offsetof(TestModel{}.LastName),
+					},
+				},
+				// 没有 pk 标签的时候 Id 就是主键
+				pks: []*FieldInfo{
+					{
+						columnName: "id",
+						fieldName:  "Id",
+						typ:        reflect.TypeOf(int64(0)),
 					},
 				},
 			},
@@ -100,3 +116,209 @@ func Test_registry_register(t *testing.T) {
 		})
 	}
 }
+
+func Test_registry_timestamp(t *testing.T) {
+	testCases := []struct {
+		name           string
+		input          any
+		wantCreateTime *FieldInfo
+		wantUpdateTime *FieldInfo
+		wantErr        error
+	}{
+		{
+			// 按照命名约定识别
+			name: "naming convention",
+			input: &struct {
+				CreateTime int64
+				UpdateTime time.Time
+			}{},
+			wantCreateTime: &FieldInfo{
+				columnName: "create_time",
+				fieldName:  "CreateTime",
+				typ:        reflect.TypeOf(int64(0)),
+				precision:  time.Millisecond,
+			},
+			wantUpdateTime: &FieldInfo{
+				columnName: "update_time",
+				fieldName:  "UpdateTime",
+				typ:        reflect.TypeOf(time.Time{}),
+				offset:     8,
+			},
+		},
+		{
+			// 使用标签
+			name: "tag",
+			input: &struct {
+				Ctime int64 `orm:"createTime=second"`
+				Utime int64 `orm:"updateTime=milli"`
+			}{},
+			wantCreateTime: &FieldInfo{
+				columnName: "ctime",
+				fieldName:  "Ctime",
+				typ:        reflect.TypeOf(int64(0)),
+				precision:  time.Second,
+			},
+			wantUpdateTime: &FieldInfo{
+				columnName: "utime",
+				fieldName:  "Utime",
+				typ:        reflect.TypeOf(int64(0)),
+				precision:  time.Millisecond,
+				offset:     8,
+			},
+		},
+		{
+			name: "invalid type",
+			input: &struct {
+				CreateTime string
+			}{},
+			wantErr: errors.New("toy-orm: 时间戳字段 CreateTime 只能是 int64 或者 time.Time"),
+		},
+		{
+			name: "invalid precision",
+			input: &struct {
+				Ctime int64 `orm:"createTime=hour"`
+			}{},
+			wantErr: errors.New("toy-orm: 字段 Ctime 非法的时间戳精度 hour"),
+		},
+	}
+	r := &registry{}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mi, err := r.register(tc.input)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantCreateTime, mi.createTime)
+			assert.Equal(t, tc.wantUpdateTime, mi.updateTime)
+		})
+	}
+}
+
+func Test_registry_softDelete(t *testing.T) {
+	testCases := []struct {
+		name    string
+		input   any
+		wantFd  string
+		wantErr error
+	}{
+		{
+			name: "time pointer",
+			input: &struct {
+				DeletedAt *time.Time `orm:"softdelete"`
+			}{},
+			wantFd: "DeletedAt",
+		},
+		{
+			name: "null int64",
+			input: &struct {
+				DeletedAt sql.NullInt64 `orm:"softdelete=second"`
+			}{},
+			wantFd: "DeletedAt",
+		},
+		{
+			// 没有标签的话不是软删除字段
+			name: "no tag",
+			input: &struct {
+				DeletedAt *time.Time
+			}{},
+		},
+		{
+			// 不能为 NULL 的类型
+			name: "invalid type",
+			input: &struct {
+				DeletedAt time.Time `orm:"softdelete"`
+			}{},
+			wantErr: errors.New("toy-orm: 软删除字段 DeletedAt 只能是 *time.Time、sql.NullTime、*int64 或者 sql.NullInt64"),
+		},
+	}
+	r := &registry{}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mi, err := r.register(tc.input)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			if tc.wantFd == "" {
+				assert.Nil(t, mi.softDelete)
+				return
+			}
+			assert.Equal(t, tc.wantFd, mi.softDelete.fieldName)
+		})
+	}
+}
+
+func Test_registry_version(t *testing.T) {
+	testCases := []struct {
+		name    string
+		input   any
+		wantFd  string
+		wantErr error
+	}{
+		{
+			name: "int64",
+			input: &struct {
+				Version int64 `orm:"version"`
+			}{},
+			wantFd: "Version",
+		},
+		{
+			name: "uint32",
+			input: &struct {
+				Ver uint32 `orm:"version"`
+			}{},
+			wantFd: "Ver",
+		},
+		{
+			name: "invalid type",
+			input: &struct {
+				Version string `orm:"version"`
+			}{},
+			wantErr: errors.New("toy-orm: 版本号字段 Version 只能是整数"),
+		},
+		{
+			name: "duplicate",
+			input: &struct {
+				Version  int64 `orm:"version"`
+				Revision int64 `orm:"version"`
+			}{},
+			wantErr: errors.New("toy-orm: 版本号字段重复 Version 和 Revision"),
+		},
+	}
+	r := &registry{}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mi, err := r.register(tc.input)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantFd, mi.version.fieldName)
+		})
+	}
+}
+
+type LegacyUser struct {
+	Id     int64  `orm:"column=ID"`
+	UserNm string `orm:"column=user_nm"`
+}
+
+func (LegacyUser) TableName() string {
+	return "t_user"
+}
+
+func Test_registry_naming(t *testing.T) {
+	r := &registry{}
+	mi, err := r.register(&LegacyUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "t_user", mi.tableName)
+	assert.Equal(t, "ID", mi.fieldMap["Id"].columnName)
+	assert.Equal(t, "UserNm", mi.columnMap["user_nm"].fieldName)
+
+	q, err := NewSelector[LegacyUser](&DB{core: core{r: r}}).Where(C("Id").EQ(1)).Build()
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT * FROM `t_user` WHERE `ID` = ?;", q.SQL)
+}
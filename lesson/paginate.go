@@ -0,0 +1,353 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+var errInvalidCursor = errors.New("toy-orm: 非法的分页游标")
+
+// MaxPageSize 是 PaginateOffset 允许的最大每页数量
+var MaxPageSize = 1000
+
+// PageRequest 是游标分页的请求。After 和 Before 最多指定一个，都为空的时候返回第一页
+type PageRequest struct {
+	// After 是 Page.Next，返回它后面的一页
+	After string
+	// Before 是 Page.Prev，返回它前面的一页
+	Before string
+	Size   int
+	// OrderBy 是排序的列，默认按照主键升序。排序的列不能为 NULL。
+	// 如果没有包含全部主键，会在最后加上主键，保证每一行的排序键都是唯一的
+	OrderBy []OrderBy
+}
+
+// Page 是一页数据
+type Page[T any] struct {
+	Items []*T
+	// Next 是最后一行的游标，没有下一页的时候为空
+	Next string
+	// Prev 是第一行的游标，没有上一页的时候为空
+	Prev string
+	// HasMore 表示沿着请求的方向是否还有数据
+	HasMore bool
+}
+
+// Paginate 使用 WHERE (a, b) > (?, ?) 定位到游标的位置再往后读，
+// 和 OFFSET 不同，翻页的代价和页码无关。sel 上的查询条件会保留，sel 本身不会被修改：
+//
+//	page, err := Paginate(ctx, NewSelector[User](db).Where(C("Age").GT(18)),
+//		PageRequest{Size: 20, OrderBy: []OrderBy{Desc("Age")}})
+//	next, err := Paginate(ctx, sel, PageRequest{After: page.Next, Size: 20, OrderBy: []OrderBy{Desc("Age")}})
+func Paginate[T any](ctx context.Context, sel *Selector[T], req PageRequest) (*Page[T], error) {
+	if req.Size <= 0 {
+		return nil, errors.New("toy-orm: 每页数量必须是正整数")
+	}
+	if req.After != "" && req.Before != "" {
+		return nil, errors.New("toy-orm: After 和 Before 不能同时指定")
+	}
+	// OFFSET 会在游标之后再跳过一些行，每一页都会丢数据
+	if sel.offset > 0 {
+		return nil, errors.New("toy-orm: 游标分页不能使用 Offset")
+	}
+	var t T
+	mi, err := sel.sess.getCore().r.get(&t)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := pageKeys(mi, sel.columns, req.OrderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	// 往前翻页的时候反过来排序，读出来之后再倒序
+	backward := req.Before != ""
+	cursor := req.After
+	if backward {
+		cursor = req.Before
+	}
+	s := *sel
+	s.where = sel.where[:len(sel.where):len(sel.where)]
+	if cursor != "" {
+		vals, err := decodeCursor(cursor, mi, keys)
+		if err != nil {
+			return nil, err
+		}
+		s.where = append(s.where, seekPredicate(mi, keys, vals, backward))
+	}
+	s.orderBy = make([]OrderBy, 0, len(keys))
+	for _, k := range keys {
+		s.orderBy = append(s.orderBy, OrderBy{name: k.name, desc: k.desc != backward})
+	}
+	// 多读一行用来判断还有没有数据
+	s.limit = req.Size + 1
+
+	items, err := s.GetMulti(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := &Page[T]{}
+	if len(items) > req.Size {
+		items = items[:req.Size]
+		res.HasMore = true
+	}
+	if backward {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+	res.Items = items
+	if len(items) == 0 {
+		return res, nil
+	}
+	first, last := items[0], items[len(items)-1]
+	if backward {
+		if res.HasMore {
+			res.Prev, err = encodeCursor(mi, keys, first)
+		}
+		if err == nil {
+			res.Next, err = encodeCursor(mi, keys, last)
+		}
+	} else {
+		if cursor != "" {
+			res.Prev, err = encodeCursor(mi, keys, first)
+		}
+		if err == nil && res.HasMore {
+			res.Next, err = encodeCursor(mi, keys, last)
+		}
+	}
+	return res, err
+}
+
+// pageKeys 返回排序键，也就是 orderBy 加上不在里面的主键
+func pageKeys(mi *ModelInfo, columns []Selectable, orderBy []OrderBy) ([]OrderBy, error) {
+	keys := make([]OrderBy, 0, len(orderBy)+len(mi.pks))
+	seen := make(map[string]bool, len(orderBy)+len(mi.pks))
+	for _, ob := range orderBy {
+		if _, ok := mi.fieldMap[ob.name]; !ok {
+			return nil, fmt.Errorf("toy-orm: 非法列名 %s", ob.name)
+		}
+		keys = append(keys, ob)
+		seen[ob.name] = true
+	}
+	desc := len(orderBy) > 0 && orderBy[len(orderBy)-1].desc
+	for _, pk := range mi.pks {
+		if !seen[pk.fieldName] {
+			keys = append(keys, OrderBy{name: pk.fieldName, desc: desc})
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("toy-orm: 分页需要排序列或者主键")
+	}
+	if len(columns) == 0 {
+		return keys, nil
+	}
+	// 游标是从结果里面读出来的，所以排序键必须被查询出来
+	selected := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		if c, ok := col.(Column); ok && c.alias == "" {
+			selected[c.name] = true
+		}
+	}
+	for _, k := range keys {
+		if !selected[k.name] {
+			return nil, fmt.Errorf("toy-orm: 排序列 %s 必须在 Select 里面", k.name)
+		}
+	}
+	return keys, nil
+}
+
+// seekPredicate 构造 keys 大于 vals 的条件，backward 的时候是小于。
+// 所有列的排序方向一致的时候使用 (a, b) > (?, ?)，
+// 否则展开为 a > ? OR (a = ? AND b < ?)
+func seekPredicate(mi *ModelInfo, keys []OrderBy, vals []any, backward bool) Predicate {
+	greater := func(k OrderBy, val any) Predicate {
+		if k.desc != backward {
+			return C(k.name).LT(val)
+		}
+		return C(k.name).GT(val)
+	}
+	if len(keys) == 1 {
+		return greater(keys[0], vals[0])
+	}
+	uniform := true
+	for _, k := range keys[1:] {
+		uniform = uniform && k.desc == keys[0].desc
+	}
+	if uniform {
+		var sb strings.Builder
+		sb.WriteByte('(')
+		for i, k := range keys {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			sb.WriteByte('`')
+			sb.WriteString(mi.fieldMap[k.name].columnName)
+			sb.WriteByte('`')
+		}
+		if keys[0].desc != backward {
+			sb.WriteString(") < (")
+		} else {
+			sb.WriteString(") > (")
+		}
+		sb.WriteString(strings.TrimSuffix(strings.Repeat("?,", len(keys)), ","))
+		sb.WriteByte(')')
+		return Raw(sb.String(), vals...).AsPredicate()
+	}
+
+	var res Predicate
+	for i, k := range keys {
+		p := greater(k, vals[i])
+		for j := i - 1; j >= 0; j-- {
+			p = C(keys[j].name).EQ(vals[j]).And(p)
+		}
+		if i == 0 {
+			res = p
+		} else {
+			res = res.Or(p)
+		}
+	}
+	return res
+}
+
+// pageCursor 是编码之前的游标，C 是排序键的列名，用来校验游标和请求是否匹配
+type pageCursor struct {
+	C []string          `json:"c"`
+	V []json.RawMessage `json:"v"`
+}
+
+func encodeCursor(mi *ModelInfo, keys []OrderBy, item any) (string, error) {
+	val := reflect.ValueOf(item).Elem()
+	pc := pageCursor{
+		C: make([]string, 0, len(keys)),
+		V: make([]json.RawMessage, 0, len(keys)),
+	}
+	for _, k := range keys {
+		data, err := json.Marshal(fieldValue(val, k.name))
+		if err != nil {
+			return "", err
+		}
+		pc.C = append(pc.C, mi.fieldMap[k.name].columnName)
+		pc.V = append(pc.V, data)
+	}
+	data, err := json.Marshal(pc)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor 按照字段的类型解析游标里面的值
+func decodeCursor(cursor string, mi *ModelInfo, keys []OrderBy) ([]any, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var pc pageCursor
+	if err = json.Unmarshal(data, &pc); err != nil || len(pc.C) != len(keys) || len(pc.V) != len(keys) {
+		return nil, errInvalidCursor
+	}
+	res := make([]any, 0, len(keys))
+	for i, k := range keys {
+		fi := mi.fieldMap[k.name]
+		if pc.C[i] != fi.columnName {
+			return nil, errInvalidCursor
+		}
+		val := reflect.New(fi.typ)
+		if err = json.Unmarshal(pc.V[i], val.Interface()); err != nil {
+			return nil, errInvalidCursor
+		}
+		res = append(res, val.Elem().Interface())
+	}
+	return res, nil
+}
+
+// OffsetPage 是按照页码分页的一页数据
+type OffsetPage[T any] struct {
+	Items []*T
+	// Total 是满足查询条件的总数
+	Total int64
+	// Page 从 1 开始
+	Page int
+	Size int
+}
+
+// PaginateOffset 使用 LIMIT 和 OFFSET 读取第 page 页，同时使用 sel 的查询条件和 From 统计总数。
+// 适合需要总数和跳页的管理后台，翻得越深越慢，大表请使用 Paginate。
+// 两个查询是分开执行的，sel 使用 Tx 的时候两个查询在同一个事务里面，看到的数据是一致的
+func PaginateOffset[T any](ctx context.Context, sel *Selector[T], page, size int) (*OffsetPage[T], error) {
+	if page < 1 {
+		return nil, errors.New("toy-orm: 页码必须从 1 开始")
+	}
+	if size < 1 || size > MaxPageSize {
+		return nil, fmt.Errorf("toy-orm: 每页数量必须在 1 到 %d 之间", MaxPageSize)
+	}
+	total, err := count(ctx, sel)
+	if err != nil {
+		return nil, err
+	}
+	res := &OffsetPage[T]{Total: total, Page: page, Size: size}
+	offset := (page - 1) * size
+	if int64(offset) >= total {
+		res.Items = []*T{}
+		return res, nil
+	}
+	s := *sel
+	s.limit = size
+	s.offset = offset
+	if res.Items, err = s.GetMulti(ctx); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// count 统计 sel 的结果数量，忽略 sel 的排序和分页。
+// 有 GROUP BY 的时候统计的是分组的数量
+func count[T any](ctx context.Context, sel *Selector[T]) (int64, error) {
+	s := *sel
+	s.orderBy = nil
+	s.limit = 0
+	s.offset = 0
+	if len(s.groupBy) == 0 {
+		s.columns = []Selectable{Count("*")}
+	}
+	q, err := s.build(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(s.groupBy) > 0 {
+		q.SQL = "SELECT COUNT(*) FROM (" + strings.TrimSuffix(q.SQL, ";") + ") AS `t`;"
+	}
+	rows, err := s.sess.query(ctx, q.SQL, q.Args...)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = rows.Close() }()
+	var res int64
+	if !rows.Next() {
+		return 0, rows.Err()
+	}
+	if err = rows.Scan(&res); err != nil {
+		return 0, err
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,420 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+)
+
+func TestPaginate(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	cursor := func(age int8, id int64) string {
+		c, err := encodeCursor(mustModel(t, db), []OrderBy{Desc("Age"), Desc("Id")},
+			&TestModel{Id: id, Age: age})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	testCases := []struct {
+		name     string
+		sel      *Selector[TestModel]
+		req      PageRequest
+		wantSQL  string
+		wantArgs []any
+		mockRows *sqlmock.Rows
+		wantIds  []int64
+		wantNext string
+		wantPrev string
+		wantMore bool
+		wantErr  error
+	}{
+		{
+			// 第一页，默认按照主键排序，多读一行
+			name:     "first page",
+			sel:      NewSelector[TestModel](db),
+			req:      PageRequest{Size: 2},
+			wantSQL:  "SELECT * FROM `test_model` ORDER BY `id` ASC LIMIT ?;",
+			wantArgs: []any{3},
+			mockRows: sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2).AddRow(3),
+			wantIds:  []int64{1, 2},
+			wantNext: "eyJjIjpbImlkIl0sInYiOlsyXX0",
+			wantMore: true,
+		},
+		{
+			// 保留原本的查询条件，最后一页没有 Next
+			name:     "after",
+			sel:      NewSelector[TestModel](db).Where(C("Age").GT(18)),
+			req:      PageRequest{After: "eyJjIjpbImlkIl0sInYiOlsyXX0", Size: 2},
+			wantSQL:  "SELECT * FROM `test_model` WHERE (`age` > ?) AND (`id` > ?) ORDER BY `id` ASC LIMIT ?;",
+			wantArgs: []any{18, int64(2), 3},
+			mockRows: sqlmock.NewRows([]string{"id"}).AddRow(3),
+			wantIds:  []int64{3},
+			wantPrev: "eyJjIjpbImlkIl0sInYiOlszXX0",
+		},
+		{
+			// 方向一致的多列使用行比较
+			name:     "row comparison",
+			sel:      NewSelector[TestModel](db),
+			req:      PageRequest{After: cursor(20, 5), Size: 1, OrderBy: []OrderBy{Desc("Age")}},
+			wantSQL:  "SELECT * FROM `test_model` WHERE (`age`,`id`) < (?,?) ORDER BY `age` DESC,`id` DESC LIMIT ?;",
+			wantArgs: []any{int8(20), int64(5), 2},
+			mockRows: sqlmock.NewRows([]string{"id", "age"}).AddRow(4, 20).AddRow(9, 19),
+			wantIds:  []int64{4},
+			wantNext: cursor(20, 4),
+			wantPrev: cursor(20, 4),
+			wantMore: true,
+		},
+		{
+			// 往前翻页的时候反过来排序，结果再倒序
+			name:     "before",
+			sel:      NewSelector[TestModel](db),
+			req:      PageRequest{Before: cursor(20, 4), Size: 2, OrderBy: []OrderBy{Desc("Age")}},
+			wantSQL:  "SELECT * FROM `test_model` WHERE (`age`,`id`) > (?,?) ORDER BY `age` ASC,`id` ASC LIMIT ?;",
+			wantArgs: []any{int8(20), int64(4), 3},
+			mockRows: sqlmock.NewRows([]string{"id", "age"}).AddRow(5, 20).AddRow(1, 21),
+			wantIds:  []int64{1, 5},
+			wantNext: cursor(20, 5),
+		},
+		{
+			// 方向不一致的时候展开
+			name: "mixed direction",
+			sel:  NewSelector[TestModel](db),
+			req: PageRequest{After: "eyJjIjpbImFnZSIsImlkIl0sInYiOlsyMCw1XX0", Size: 1,
+				OrderBy: []OrderBy{Desc("Age"), Asc("Id")}},
+			wantSQL: "SELECT * FROM `test_model` WHERE (`age` < ?) OR ((`age` = ?) AND (`id` > ?)) " +
+				"ORDER BY `age` DESC,`id` ASC LIMIT ?;",
+			wantArgs: []any{int8(20), int8(20), int64(5), 2},
+			mockRows: sqlmock.NewRows([]string{"id"}),
+		},
+		{
+			name:    "invalid size",
+			sel:     NewSelector[TestModel](db),
+			req:     PageRequest{},
+			wantErr: errors.New("toy-orm: 每页数量必须是正整数"),
+		},
+		{
+			name:    "after and before",
+			sel:     NewSelector[TestModel](db),
+			req:     PageRequest{After: "a", Before: "b", Size: 1},
+			wantErr: errors.New("toy-orm: After 和 Before 不能同时指定"),
+		},
+		{
+			name:    "offset",
+			sel:     NewSelector[TestModel](db).Offset(10),
+			req:     PageRequest{Size: 1},
+			wantErr: errors.New("toy-orm: 游标分页不能使用 Offset"),
+		},
+		{
+			// 游标和排序的列对不上
+			name:    "cursor mismatch",
+			sel:     NewSelector[TestModel](db),
+			req:     PageRequest{After: "eyJjIjpbImlkIl0sInYiOlsyXX0", Size: 1, OrderBy: []OrderBy{Desc("Age")}},
+			wantErr: errInvalidCursor,
+		},
+		{
+			name:    "malformed cursor",
+			sel:     NewSelector[TestModel](db),
+			req:     PageRequest{After: "!!!", Size: 1},
+			wantErr: errInvalidCursor,
+		},
+		{
+			name:    "not selected",
+			sel:     NewSelector[TestModel](db).Select(C("FirstName")),
+			req:     PageRequest{Size: 1},
+			wantErr: errors.New("toy-orm: 排序列 Id 必须在 Select 里面"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.mockRows != nil {
+				mock.ExpectQuery(regexp.QuoteMeta(tc.wantSQL)).
+					WithArgs(toDriverArgs(tc.wantArgs)...).WillReturnRows(tc.mockRows)
+			}
+			page, err := Paginate(ctx, tc.sel, tc.req)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			ids := make([]int64, 0, len(page.Items))
+			for _, tm := range page.Items {
+				ids = append(ids, tm.Id)
+			}
+			if tc.wantIds == nil {
+				tc.wantIds = []int64{}
+			}
+			assert.Equal(t, tc.wantIds, ids)
+			assert.Equal(t, tc.wantNext, page.Next)
+			assert.Equal(t, tc.wantPrev, page.Prev)
+			assert.Equal(t, tc.wantMore, page.HasMore)
+			assert.Nil(t, mock.ExpectationsWereMet())
+		})
+	}
+
+	// 没有主键也没有排序列的时候无法生成游标
+	_, err = Paginate(ctx, NewSelector[NoKeyModel](db), PageRequest{Size: 1})
+	assert.Equal(t, errors.New("toy-orm: 分页需要排序列或者主键"), err)
+}
+
+func TestPaginate_SQLite(t *testing.T) {
+	db, err := NewDB("sqlite3", "file:paginate.db?cache=shared&mode=memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	ctx := context.Background()
+	if err = db.CreateTables(ctx, &TestModel{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 7; i++ {
+		tm := &TestModel{Id: int64(i), FirstName: fmt.Sprintf("user%d", i), Age: int8(20 + i%3)}
+		if _, err = NewInserter[TestModel](db).Values(tm).Exec(ctx).RowsAffected(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 年龄倒序：(22, 2) (22, 5) (21, 1) (21, 4) (21, 7) (20, 3) (20, 6)，相同年龄按照 id 升序
+	obs := []OrderBy{Desc("Age"), Asc("Id")}
+	ids := func(p *Page[TestModel]) []int64 {
+		res := make([]int64, 0, len(p.Items))
+		for _, tm := range p.Items {
+			res = append(res, tm.Id)
+		}
+		return res
+	}
+
+	var pages [][]int64
+	req := PageRequest{Size: 3, OrderBy: obs}
+	var last *Page[TestModel]
+	for {
+		last, err = Paginate(ctx, NewSelector[TestModel](db), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, ids(last))
+		if !last.HasMore {
+			break
+		}
+		req.After = last.Next
+	}
+	assert.Equal(t, [][]int64{{2, 5, 1}, {4, 7, 3}, {6}}, pages)
+
+	// 从最后一页往回翻
+	prev, err := Paginate(ctx, NewSelector[TestModel](db), PageRequest{Before: last.Prev, Size: 3, OrderBy: obs})
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{4, 7, 3}, ids(prev))
+	assert.True(t, prev.HasMore)
+	prev, err = Paginate(ctx, NewSelector[TestModel](db), PageRequest{Before: prev.Prev, Size: 3, OrderBy: obs})
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{2, 5, 1}, ids(prev))
+	assert.False(t, prev.HasMore)
+	assert.Equal(t, "", prev.Prev)
+
+	// 方向一致的时候使用行比较：(22, 5) (22, 2) (21, 7) (21, 4) ...
+	page, err := Paginate(ctx, NewSelector[TestModel](db), PageRequest{Size: 2, OrderBy: []OrderBy{Desc("Age")}})
+	assert.Nil(t, err)
+	page, err = Paginate(ctx, NewSelector[TestModel](db),
+		PageRequest{After: page.Next, Size: 2, OrderBy: []OrderBy{Desc("Age")}})
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{7, 4}, ids(page))
+}
+
+func TestPaginateOffset(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	testCases := []struct {
+		name      string
+		sel       *Selector[TestModel]
+		page      int
+		size      int
+		mockOrder func(mock sqlmock.Sqlmock)
+		wantTotal int64
+		wantIds   []int64
+		wantErr   error
+	}{
+		{
+			// 统计的时候去掉排序和分页
+			name: "page",
+			sel:  NewSelector[TestModel](db).Where(C("Age").GT(18)).OrderBy(Desc("Id")),
+			page: 2,
+			size: 2,
+			mockOrder: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM `test_model` WHERE `age` > ?;")).
+					WithArgs(18).WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(5))
+				mock.ExpectQuery(regexp.QuoteMeta(
+					"SELECT * FROM `test_model` WHERE `age` > ? ORDER BY `id` DESC LIMIT ? OFFSET ?;")).
+					WithArgs(18, 2, 2).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3).AddRow(2))
+			},
+			wantTotal: 5,
+			wantIds:   []int64{3, 2},
+		},
+		{
+			// 超出总数的时候不查询数据
+			name: "out of range",
+			sel:  NewSelector[TestModel](db).From("`test_model` AS `t1` JOIN `test_model` AS `t2`"),
+			page: 3,
+			size: 2,
+			mockOrder: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM `test_model` AS `t1` JOIN `test_model` AS `t2`;")).
+					WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(4))
+			},
+			wantTotal: 4,
+			wantIds:   []int64{},
+		},
+		{
+			// GROUP BY 统计的是分组的数量
+			name: "group by",
+			sel:  NewSelector[TestModel](db).Select(C("Age")).GroupBy(C("Age")),
+			page: 1,
+			size: 10,
+			mockOrder: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(
+					"SELECT COUNT(*) FROM (SELECT `age` FROM `test_model` GROUP BY `age`) AS `t`;")).
+					WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT `age` FROM `test_model` GROUP BY `age` LIMIT ?;")).
+					WithArgs(10).WillReturnRows(sqlmock.NewRows([]string{"age"}).AddRow(18))
+			},
+			wantTotal: 1,
+			wantIds:   []int64{0},
+		},
+		{
+			name: "count error",
+			sel:  NewSelector[TestModel](db),
+			page: 1,
+			size: 10,
+			mockOrder: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT COUNT.*").WillReturnError(errors.New("count error"))
+			},
+			wantErr: errors.New("count error"),
+		},
+		{
+			name:    "invalid page",
+			sel:     NewSelector[TestModel](db),
+			size:    10,
+			wantErr: errors.New("toy-orm: 页码必须从 1 开始"),
+		},
+		{
+			name:    "size too large",
+			sel:     NewSelector[TestModel](db),
+			page:    1,
+			size:    MaxPageSize + 1,
+			wantErr: errors.New("toy-orm: 每页数量必须在 1 到 1000 之间"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.mockOrder != nil {
+				tc.mockOrder(mock)
+			}
+			page, err := PaginateOffset(ctx, tc.sel, tc.page, tc.size)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			ids := make([]int64, 0, len(page.Items))
+			for _, tm := range page.Items {
+				ids = append(ids, tm.Id)
+			}
+			assert.Equal(t, tc.wantIds, ids)
+			assert.Equal(t, tc.wantTotal, page.Total)
+			assert.Equal(t, tc.page, page.Page)
+			assert.Equal(t, tc.size, page.Size)
+			assert.Nil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestPaginateOffset_SQLite(t *testing.T) {
+	db, err := NewDB("sqlite3", "file:paginate_offset.db?cache=shared&mode=memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	ctx := context.Background()
+	if err = db.CreateTables(ctx, &TestModel{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 5; i++ {
+		if _, err = NewInserter[TestModel](db).Values(&TestModel{Id: int64(i), Age: int8(i)}).
+			Exec(ctx).RowsAffected(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 在事务里面分页，统计和查询看到的是同一份数据
+	tx, err := db.Begin(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err = NewInserter[TestModel](tx).Values(&TestModel{Id: 6, Age: 6}).Exec(ctx).RowsAffected(); err != nil {
+		t.Fatal(err)
+	}
+	page, err := PaginateOffset(ctx, NewSelector[TestModel](tx).Where(C("Age").GT(1)).OrderBy(Asc("Id")), 2, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), page.Total)
+	assert.Equal(t, 2, len(page.Items))
+	assert.Equal(t, int64(5), page.Items[0].Id)
+	assert.Equal(t, int64(6), page.Items[1].Id)
+
+	// 只有 OFFSET 的时候读取剩下的所有数据
+	tms, err := NewSelector[TestModel](tx).OrderBy(Asc("Id")).Offset(4).GetMulti(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(tms))
+	assert.Equal(t, int64(5), tms[0].Id)
+}
+
+func mustModel(t *testing.T, db *DB) *ModelInfo {
+	mi, err := db.r.get(&TestModel{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mi
+}
+
+// toDriverArgs 把参数转换为驱动收到的类型，例如 int8 转换为 int64
+func toDriverArgs(args []any) []driver.Value {
+	res := make([]driver.Value, 0, len(args))
+	for _, arg := range args {
+		val, _ := driver.DefaultParameterConverter.ConvertValue(arg)
+		res = append(res, val)
+	}
+	return res
+}
@@ -0,0 +1,153 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// GetByPK 根据主键查询，联合主键的时候按照主键声明的顺序传入，例如：
+//
+//	GetByPK[User](ctx, db, 1)
+//	GetByPK[UserRole](ctx, db, userId, roleId)
+//
+// 和 Selector 一样会加上 Scope 和软删除的条件
+func GetByPK[T any](ctx context.Context, sess Session, pk ...any) (*T, error) {
+	p, err := pkPredicate[T](sess, pk)
+	if err != nil {
+		return nil, err
+	}
+	return NewSelector[T](sess).Where(p).Get(ctx)
+}
+
+// GetByPKs 使用一个查询读取多个主键对应的数据，结果按照 pks 的顺序排列，
+// 不存在的主键会被跳过，重复的主键只返回一次。
+// 联合主键的时候 pks 的每一个元素是按照主键顺序排列的 []any
+func GetByPKs[T any](ctx context.Context, sess Session, pks ...any) ([]*T, error) {
+	if len(pks) == 0 {
+		return []*T{}, nil
+	}
+	mi, err := sess.getCore().r.get(new(T))
+	if err != nil {
+		return nil, err
+	}
+	if len(mi.pks) == 0 {
+		return nil, fmt.Errorf("toy-orm: 表 %s 没有主键", mi.tableName)
+	}
+	keys := make([][]any, 0, len(pks))
+	for _, pk := range pks {
+		if len(mi.pks) == 1 {
+			keys = append(keys, []any{pk})
+			continue
+		}
+		vals, ok := pk.([]any)
+		if !ok {
+			return nil, fmt.Errorf("toy-orm: 联合主键需要使用 []any，传入的是 %T", pk)
+		}
+		keys = append(keys, vals)
+	}
+
+	var p Predicate
+	if len(mi.pks) == 1 {
+		p = C(mi.pks[0].fieldName).In(pks...)
+	} else {
+		for i, vals := range keys {
+			kp, err := pkPredicateOf(mi, vals)
+			if err != nil {
+				return nil, err
+			}
+			if i == 0 {
+				p = kp
+			} else {
+				p = p.Or(kp)
+			}
+		}
+	}
+	rows, err := NewSelector[T](sess).Where(p).GetMulti(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 参数的类型可能和字段的类型不一样，例如 int 和 int64、int64 和 sql.NullInt64，
+	// 所以按照 pkKey 的结果比较
+	found := make(map[string]*T, len(rows))
+	for _, tp := range rows {
+		val := reflect.ValueOf(tp).Elem()
+		vals := make([]any, 0, len(mi.pks))
+		for _, pk := range mi.pks {
+			vals = append(vals, fieldValue(val, pk.fieldName))
+		}
+		found[pkKey(vals)] = tp
+	}
+	res := make([]*T, 0, len(rows))
+	for _, vals := range keys {
+		k := pkKey(vals)
+		if tp, ok := found[k]; ok {
+			res = append(res, tp)
+			delete(found, k)
+		}
+	}
+	return res, nil
+}
+
+// DeleteByPK 根据主键删除，软删除的模型会被标记为删除
+func DeleteByPK[T any](ctx context.Context, sess Session, pk ...any) sql.Result {
+	p, err := pkPredicate[T](sess, pk)
+	if err != nil {
+		return Result{err: err}
+	}
+	return NewDeleter[T](sess).Where(p).Exec(ctx)
+}
+
+// pkKey 把主键的值拼接为 map 的 key，值会先经过 normalizeKey，
+// 并且带上类型，避免 1 和 "1" 得到同一个 key
+func pkKey(vals []any) string {
+	var sb strings.Builder
+	for i, val := range vals {
+		if i > 0 {
+			sb.WriteByte(0)
+		}
+		key, _ := normalizeKey(reflect.ValueOf(val))
+		_, _ = fmt.Fprintf(&sb, "%T:%v", key, key)
+	}
+	return sb.String()
+}
+
+func pkPredicate[T any](sess Session, vals []any) (Predicate, error) {
+	mi, err := sess.getCore().r.get(new(T))
+	if err != nil {
+		return Predicate{}, err
+	}
+	return pkPredicateOf(mi, vals)
+}
+
+// pkPredicateOf 构造主键等于 vals 的条件
+func pkPredicateOf(mi *ModelInfo, vals []any) (Predicate, error) {
+	if len(mi.pks) == 0 {
+		return Predicate{}, fmt.Errorf("toy-orm: 表 %s 没有主键", mi.tableName)
+	}
+	if len(vals) != len(mi.pks) {
+		return Predicate{}, fmt.Errorf("toy-orm: 主键有 %d 列，传入了 %d 个值", len(mi.pks), len(vals))
+	}
+	p := C(mi.pks[0].fieldName).EQ(vals[0])
+	for i := 1; i < len(vals); i++ {
+		p = p.And(C(mi.pks[i].fieldName).EQ(vals[i]))
+	}
+	return p, nil
+}
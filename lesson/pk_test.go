@@ -0,0 +1,225 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+)
+
+type NoKeyModel struct {
+	Name string
+}
+
+type NullKeyModel struct {
+	Id   sql.NullInt64 `orm:"pk"`
+	Name string
+}
+
+func TestGetByPK(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `test_model` WHERE `id` = ?;")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "first_name"}).AddRow(1, "Tom"))
+	tm, err := GetByPK[TestModel](ctx, db, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, &TestModel{Id: 1, FirstName: "Tom"}, tm)
+
+	// 联合主键
+	mock.ExpectQuery(regexp.QuoteMeta(
+		"SELECT * FROM `schema_user_role` WHERE (`user_id` = ?) AND (`role_id` = ?);")).WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "role_id"}).AddRow(1, 2))
+	ur, err := GetByPK[SchemaUserRole](ctx, db, 1, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, &SchemaUserRole{UserId: 1, RoleId: 2}, ur)
+
+	// 软删除
+	mock.ExpectQuery(regexp.QuoteMeta(
+		"SELECT * FROM `soft_delete_model` WHERE (`id` = ?) AND (`deleted_at` IS NULL);")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	_, err = GetByPK[SoftDeleteModel](ctx, db, 1)
+	assert.Equal(t, errors.New("toy-orm: 未找到数据"), err)
+
+	_, err = GetByPK[SchemaUserRole](ctx, db, 1)
+	assert.Equal(t, errors.New("toy-orm: 主键有 2 列，传入了 1 个值"), err)
+	_, err = GetByPK[NoKeyModel](ctx, db, 1)
+	assert.Equal(t, errors.New("toy-orm: 表 no_key_model 没有主键"), err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestGetByPKs(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	testCases := []struct {
+		name     string
+		get      func() (any, error)
+		wantSQL  string
+		wantArgs []any
+		mockRows *sqlmock.Rows
+		want     any
+		wantErr  error
+	}{
+		{
+			// 结果按照传入的顺序排列，不存在的跳过，重复的只返回一次
+			name: "order",
+			get: func() (any, error) {
+				return GetByPKs[TestModel](ctx, db, 3, 1, 2, 3)
+			},
+			wantSQL:  "SELECT * FROM `test_model` WHERE `id` IN (?,?,?,?);",
+			wantArgs: []any{3, 1, 2, 3},
+			mockRows: sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(3),
+			want:     []*TestModel{{Id: 3}, {Id: 1}},
+		},
+		{
+			name: "composite",
+			get: func() (any, error) {
+				return GetByPKs[SchemaUserRole](ctx, db, []any{2, 1}, []any{1, 2})
+			},
+			wantSQL:  "SELECT * FROM `schema_user_role` WHERE ((`user_id` = ?) AND (`role_id` = ?)) OR ((`user_id` = ?) AND (`role_id` = ?));",
+			wantArgs: []any{2, 1, 1, 2},
+			mockRows: sqlmock.NewRows([]string{"user_id", "role_id"}).AddRow(1, 2).AddRow(2, 1),
+			want:     []*SchemaUserRole{{UserId: 2, RoleId: 1}, {UserId: 1, RoleId: 2}},
+		},
+		{
+			// 主键是 sql.NullInt64 的时候也能和传入的整数对应上
+			name: "valuer",
+			get: func() (any, error) {
+				return GetByPKs[NullKeyModel](ctx, db, 2, int64(1))
+			},
+			wantSQL:  "SELECT * FROM `null_key_model` WHERE `id` IN (?,?);",
+			wantArgs: []any{2, int64(1)},
+			mockRows: sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2),
+			want: []*NullKeyModel{
+				{Id: sql.NullInt64{Int64: 2, Valid: true}},
+				{Id: sql.NullInt64{Int64: 1, Valid: true}},
+			},
+		},
+		{
+			// 不发起查询
+			name: "empty",
+			get: func() (any, error) {
+				return GetByPKs[TestModel](ctx, db)
+			},
+			want: []*TestModel{},
+		},
+		{
+			name: "composite without slice",
+			get: func() (any, error) {
+				return GetByPKs[SchemaUserRole](ctx, db, 1)
+			},
+			wantErr: errors.New("toy-orm: 联合主键需要使用 []any，传入的是 int"),
+		},
+		{
+			name: "composite length",
+			get: func() (any, error) {
+				return GetByPKs[SchemaUserRole](ctx, db, []any{1})
+			},
+			wantErr: errors.New("toy-orm: 主键有 2 列，传入了 1 个值"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.mockRows != nil {
+				mock.ExpectQuery(regexp.QuoteMeta(tc.wantSQL)).
+					WithArgs(toDriverArgs(tc.wantArgs)...).WillReturnRows(tc.mockRows)
+			}
+			res, err := tc.get()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.want, res)
+			assert.Nil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_pkKey(t *testing.T) {
+	n := int64(1)
+	testCases := []struct {
+		name string
+		a    []any
+		b    []any
+		want bool
+	}{
+		{name: "int and int64", a: []any{1}, b: []any{int64(1)}, want: true},
+		{name: "uint", a: []any{uint8(1)}, b: []any{1}, want: true},
+		{name: "pointer", a: []any{&n}, b: []any{1}, want: true},
+		{name: "valuer", a: []any{sql.NullInt64{Int64: 1, Valid: true}}, b: []any{1}, want: true},
+		{name: "null", a: []any{sql.NullInt64{}}, b: []any{(*int64)(nil)}, want: true},
+		{name: "bytes", a: []any{[]byte("a")}, b: []any{"a"}, want: true},
+		{name: "int and string", a: []any{1}, b: []any{"1"}},
+		{name: "composite", a: []any{1, 2}, b: []any{12}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, pkKey(tc.a) == pkKey(tc.b))
+		})
+	}
+}
+
+func TestDeleteByPK(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	mock.ExpectExec(regexp.QuoteMeta(
+		"DELETE FROM `schema_user_role` WHERE (`user_id` = ?) AND (`role_id` = ?);")).WithArgs(1, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	affected, err := DeleteByPK[SchemaUserRole](ctx, db, 1, 2).RowsAffected()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), affected)
+
+	// 软删除的模型只是标记为删除
+	mock.ExpectExec(regexp.QuoteMeta(
+		"UPDATE `soft_delete_model` SET `deleted_at`=? WHERE (`id` = ?) AND (`deleted_at` IS NULL);")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	_, err = DeleteByPK[SoftDeleteModel](ctx, db, 1).RowsAffected()
+	assert.Nil(t, err)
+
+	_, err = DeleteByPK[TestModel](ctx, db).RowsAffected()
+	assert.Equal(t, errors.New("toy-orm: 主键有 1 列，传入了 0 个值"), err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
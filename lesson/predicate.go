@@ -23,6 +23,12 @@ const (
 	opAND = "AND"
 	opOR  = "OR"
 	opNOT = "NOT"
+	opIN  = "IN"
+
+	opLIKE = "LIKE"
+
+	opIsNull    = "IS NULL"
+	opIsNotNull = "IS NOT NULL"
 )
 
 func (o op) String() string {
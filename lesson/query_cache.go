@@ -0,0 +1,262 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"container/list"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Cache 是查询缓存的存储，val 是查询结果，只需要原样保存。
+// 除了查询结果，Cache 里面还会保存每张表的版本号，key 以 toy-orm:gen: 开头
+type Cache interface {
+	Get(ctx context.Context, key string) (any, bool)
+	// Set 的 ttl 小于等于 0 的时候不过期
+	Set(ctx context.Context, key string, val any, ttl time.Duration)
+}
+
+// DBWithQueryCache 缓存 Selector 的 Get 和 GetMulti 的结果，ttl 是缓存的过期时间。
+// 通过 ORM 对一张表执行 INSERT、UPDATE、DELETE 之后，这张表的缓存全部失效。
+// 表的版本号也保存在 c 里面，所以多个进程共享同一个 Cache 的时候，
+// 一个进程的修改也会让别的进程的缓存失效。
+// 以下情况不使用缓存：事务里面的查询、使用了 Preload 或者 From 的查询。
+// RawExec 和绕过 ORM 的修改不会让缓存失效。
+//
+// 命中缓存的时候不会执行 AfterSelect 钩子，返回的是第一次查询执行钩子之后的结果。
+// 每次返回的都是结构体的浅拷贝，结构体里面的切片、map 和指针指向的数据是共享的，不能修改
+func DBWithQueryCache(c Cache, ttl time.Duration) DBOption {
+	return func(db *DB) {
+		db.qcache = &queryCache{
+			cache: c,
+			ttl:   ttl,
+		}
+	}
+}
+
+// queryCache 给每张表维护一个版本号，版本号是 key 的一部分，
+// 修改表的时候换一个新的版本号，旧的缓存就不会再被读到，之后由 Cache 淘汰
+type queryCache struct {
+	cache Cache
+	ttl   time.Duration
+	group singleflight
+}
+
+func (qc *queryCache) key(ctx context.Context, table string, typ reflect.Type, first bool, q *Query) string {
+	// 指针直接格式化的是地址，所以先转换为指向的值，driver.Valuer 使用 Value 的结果
+	args := make([]any, 0, len(q.Args))
+	for _, arg := range q.Args {
+		if key, ok := normalizeKey(reflect.ValueOf(arg)); ok {
+			arg = key
+		} else if isNullValue(arg) {
+			arg = nil
+		}
+		args = append(args, arg)
+	}
+	// %#v 可以区分 1 和 "1"
+	return fmt.Sprintf("%s:%s:%s:%t:%s:%#v", table, qc.gen(ctx, table), typ, first, q.SQL, args)
+}
+
+func genKey(table string) string {
+	return "toy-orm:gen:" + table
+}
+
+// gen 返回表的版本号。版本号不存在的时候，例如第一次查询或者被 Cache 淘汰了，
+// 生成一个新的版本号，之前的缓存都不会再被读到
+func (qc *queryCache) gen(ctx context.Context, table string) string {
+	if val, ok := qc.cache.Get(ctx, genKey(table)); ok {
+		if gen, ok := val.(string); ok {
+			return gen
+		}
+	}
+	return qc.invalidate(ctx, table)
+}
+
+// invalidate 使用随机的版本号，多个进程同时修改的时候也不会得到同一个版本号，
+// 进程重启之后也不会重新使用旧的版本号
+func (qc *queryCache) invalidate(ctx context.Context, table string) string {
+	gen := newGen()
+	qc.cache.Set(ctx, genKey(table), gen, 0)
+	return gen
+}
+
+func newGen() string {
+	var buf [8]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(buf[:])
+}
+
+// invalidateCache 让 table 的缓存失效。在事务里面的时候，提交之后会再失效一次，
+// 避免别的查询在提交之前把旧数据放回缓存
+func invalidateCache(ctx context.Context, sess Session, table string) {
+	qc := sess.getCore().qcache
+	if qc == nil {
+		return
+	}
+	qc.invalidate(ctx, table)
+	if tx, ok := sess.(*Tx); ok {
+		tx.dirty = append(tx.dirty, table)
+	}
+}
+
+// cachedQuery 先读缓存，没有命中的时候执行 load 并写入缓存。
+// 缓存保存的是结构体的值，每次返回新的指针，调用者修改结果的字段不会影响缓存
+func cachedQuery[T any](ctx context.Context, qc *queryCache, table string, first bool,
+	q *Query, load func() ([]*T, error)) ([]*T, error) {
+	key := qc.key(ctx, table, reflect.TypeOf(new(T)), first, q)
+	val, ok := qc.cache.Get(ctx, key)
+	if !ok {
+		var err error
+		// 同一个 key 同时只有一个查询访问数据库
+		val, err = qc.group.do(key, func() (any, error) {
+			res, err := load()
+			if err != nil {
+				return nil, err
+			}
+			vals := make([]T, 0, len(res))
+			for _, tp := range res {
+				vals = append(vals, *tp)
+			}
+			qc.cache.Set(ctx, key, vals, qc.ttl)
+			return vals, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	vals := val.([]T)
+	res := make([]*T, 0, len(vals))
+	for i := range vals {
+		tp := new(T)
+		*tp = vals[i]
+		res = append(res, tp)
+	}
+	return res, nil
+}
+
+// singleflight 合并同一个 key 的并发调用
+type singleflight struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+type call struct {
+	wg  sync.WaitGroup
+	val any
+	err error
+}
+
+func (g *singleflight) do(key string, fn func() (any, error)) (any, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.val, c.err
+	}
+	c := &call{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		c.wg.Done()
+	}()
+	c.val, c.err = fn()
+	return c.val, c.err
+}
+
+// MemoryCache 是进程内的 LRU 缓存，超过容量的时候淘汰最久没有使用的数据
+type MemoryCache struct {
+	capacity int
+	clock    func() time.Time
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type memoryEntry struct {
+	key string
+	val any
+	// deadline 为零值的时候不过期
+	deadline time.Time
+}
+
+func NewMemoryCache(capacity int) *MemoryCache {
+	return &MemoryCache{
+		capacity: capacity,
+		clock:    time.Now,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element, capacity),
+	}
+}
+
+func (m *MemoryCache) Get(_ context.Context, key string) (any, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	elem, ok := m.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := elem.Value.(*memoryEntry)
+	if !e.deadline.IsZero() && !m.clock().Before(e.deadline) {
+		m.ll.Remove(elem)
+		delete(m.items, key)
+		return nil, false
+	}
+	m.ll.MoveToFront(elem)
+	return e.val, true
+}
+
+func (m *MemoryCache) Set(_ context.Context, key string, val any, ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var deadline time.Time
+	if ttl > 0 {
+		deadline = m.clock().Add(ttl)
+	}
+	if elem, ok := m.items[key]; ok {
+		e := elem.Value.(*memoryEntry)
+		e.val, e.deadline = val, deadline
+		m.ll.MoveToFront(elem)
+		return
+	}
+	m.items[key] = m.ll.PushFront(&memoryEntry{key: key, val: val, deadline: deadline})
+	for m.ll.Len() > m.capacity {
+		e := m.ll.Remove(m.ll.Back()).(*memoryEntry)
+		delete(m.items, e.key)
+	}
+}
+
+// Len 返回缓存的数量，包括已经过期但是还没有被淘汰的
+func (m *MemoryCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.ll.Len()
+}
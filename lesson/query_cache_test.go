@@ -0,0 +1,214 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSelector_QueryCache(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB, DBWithQueryCache(NewMemoryCache(16), time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	selectSQL := regexp.QuoteMeta("SELECT * FROM `test_model` WHERE `age` > ?;")
+
+	// 第二次查询命中缓存
+	mock.ExpectQuery(selectSQL).WithArgs(18).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "first_name"}).AddRow(1, "Tom").AddRow(2, "Jerry"))
+	tms, err := NewSelector[TestModel](db).Where(C("Age").GT(18)).GetMulti(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(tms))
+	// 修改返回的结果不会影响缓存
+	tms[0].FirstName = "changed"
+	tms, err = NewSelector[TestModel](db).Where(C("Age").GT(18)).GetMulti(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []*TestModel{{Id: 1, FirstName: "Tom"}, {Id: 2, FirstName: "Jerry"}}, tms)
+
+	// 参数不同是不同的缓存
+	mock.ExpectQuery(selectSQL).WithArgs("18").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	tms, err = NewSelector[TestModel](db).Where(C("Age").GT("18")).GetMulti(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []*TestModel{{Id: 3}}, tms)
+
+	// 指针参数按照指向的值缓存
+	mock.ExpectQuery(selectSQL).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	age1, age2 := int64(30), int64(30)
+	tms, err = NewSelector[TestModel](db).Where(C("Age").GT(&age1)).GetMulti(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []*TestModel{{Id: 5}}, tms)
+	tms, err = NewSelector[TestModel](db).Where(C("Age").GT(&age2)).GetMulti(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []*TestModel{{Id: 5}}, tms)
+
+	// Get 和 GetMulti 分开缓存，没有数据的时候不缓存
+	mock.ExpectQuery(selectSQL).WithArgs(18).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(selectSQL).WithArgs(18).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	_, err = NewSelector[TestModel](db).Where(C("Age").GT(18)).Get(ctx)
+	assert.Equal(t, errors.New("toy-orm: 未找到数据"), err)
+	tm, err := NewSelector[TestModel](db).Where(C("Age").GT(18)).Get(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, &TestModel{Id: 1}, tm)
+	tm, err = NewSelector[TestModel](db).Where(C("Age").GT(18)).Get(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, &TestModel{Id: 1}, tm)
+
+	// 插入之后缓存失效
+	mock.ExpectExec("INSERT .*").WillReturnResult(sqlmock.NewResult(4, 1))
+	mock.ExpectQuery(selectSQL).WithArgs(18).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(4))
+	_, err = NewInserter[TestModel](db).Values(&TestModel{Id: 4, Age: 20}).Exec(ctx).RowsAffected()
+	assert.Nil(t, err)
+	tms, err = NewSelector[TestModel](db).Where(C("Age").GT(18)).GetMulti(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(tms))
+
+	// 事务里面的查询不使用缓存，提交之后缓存失效
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectSQL).WithArgs(18).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("DELETE .*").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(selectSQL).WithArgs(18).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	tx, err := db.Begin(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tms, err = NewSelector[TestModel](tx).Where(C("Age").GT(18)).GetMulti(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(tms))
+	_, err = NewDeleter[TestModel](tx).Where(C("Id").EQ(4)).Exec(ctx).RowsAffected()
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Commit())
+	tms, err = NewSelector[TestModel](db).Where(C("Age").GT(18)).GetMulti(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(tms))
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestSelector_QueryCache_Shared(t *testing.T) {
+	// 两个 DB 模拟共享同一个 Cache 的两个进程
+	cache := NewMemoryCache(16)
+	mockDB1, mock1, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB1.Close() }()
+	db1, err := newDB(mockDB1, DBWithQueryCache(cache, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mockDB2, mock2, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB2.Close() }()
+	db2, err := newDB(mockDB2, DBWithQueryCache(cache, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// db1 的查询结果 db2 也可以用
+	mock1.ExpectQuery("SELECT .*").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	tm, err := NewSelector[TestModel](db1).Where(C("Id").EQ(1)).Get(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, &TestModel{Id: 1}, tm)
+	tm, err = NewSelector[TestModel](db2).Where(C("Id").EQ(1)).Get(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, &TestModel{Id: 1}, tm)
+
+	// db2 修改之后 db1 的缓存也失效了
+	mock2.ExpectExec("UPDATE .*").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock1.ExpectQuery("SELECT .*").WillReturnRows(sqlmock.NewRows([]string{"id", "first_name"}).AddRow(1, "Tom"))
+	_, err = NewUpdater[TestModel](db2).Set(Assign("FirstName", "Tom")).Where(C("Id").EQ(1)).
+		Exec(ctx).RowsAffected()
+	assert.Nil(t, err)
+	tm, err = NewSelector[TestModel](db1).Where(C("Id").EQ(1)).Get(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, &TestModel{Id: 1, FirstName: "Tom"}, tm)
+
+	assert.Nil(t, mock1.ExpectationsWereMet())
+	assert.Nil(t, mock2.ExpectationsWereMet())
+}
+
+func TestSelector_QueryCache_Singleflight(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB, DBWithQueryCache(NewMemoryCache(16), 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// 只有一次查询会发给数据库
+	mock.ExpectQuery("SELECT .*").WillDelayFor(50 * time.Millisecond).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tm, err := NewSelector[TestModel](db).Where(C("Id").EQ(1)).Get(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, &TestModel{Id: 1}, tm)
+		}()
+	}
+	wg.Wait()
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestMemoryCache(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	c := NewMemoryCache(2)
+	c.clock = func() time.Time { return now }
+
+	c.Set(ctx, "a", 1, time.Second)
+	c.Set(ctx, "b", 2, 0)
+	_, ok := c.Get(ctx, "a")
+	assert.True(t, ok)
+	// 容量是 2，淘汰最久没有使用的 b
+	c.Set(ctx, "c", 3, 0)
+	_, ok = c.Get(ctx, "b")
+	assert.False(t, ok)
+	assert.Equal(t, 2, c.Len())
+
+	// a 过期之后读不到
+	now = now.Add(time.Second)
+	_, ok = c.Get(ctx, "a")
+	assert.False(t, ok)
+	val, ok := c.Get(ctx, "c")
+	assert.True(t, ok)
+	assert.Equal(t, 3, val)
+	assert.Equal(t, 1, c.Len())
+}
@@ -0,0 +1,228 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// RawQuerier 执行手写的查询，结果集和 Selector 一样映射为 T。
+// 手写的 SQL 不会加上 Scope 和软删除的条件
+type RawQuerier[T any] struct {
+	sess  Session
+	query string
+	args  []any
+}
+
+// RawQuery 例如 RawQuery[User](db, "SELECT * FROM `user` WHERE `age` > ?", 18)。
+// 也可以使用 :name 或者 @name 形式的命名参数，这个时候 args 只能是一个 map[string]any 或者结构体：
+//
+//	RawQuery[User](db, "SELECT * FROM `user` WHERE `id` IN (:ids)", map[string]any{"ids": []int{1, 2}})
+func RawQuery[T any](sess Session, query string, args ...any) *RawQuerier[T] {
+	return &RawQuerier[T]{
+		sess:  sess,
+		query: query,
+		args:  args,
+	}
+}
+
+func (r *RawQuerier[T]) Build() (*Query, error) {
+	return bindNamed(r.query, r.args)
+}
+
+func (r *RawQuerier[T]) Get(ctx context.Context) (*T, error) {
+	q, mi, err := r.build()
+	if err != nil {
+		return nil, err
+	}
+	return getOne[T](ctx, r.sess, mi, q, nil)
+}
+
+func (r *RawQuerier[T]) GetMulti(ctx context.Context) ([]*T, error) {
+	q, mi, err := r.build()
+	if err != nil {
+		return nil, err
+	}
+	return getMulti[T](ctx, r.sess, mi, q, nil)
+}
+
+func (r *RawQuerier[T]) build() (*Query, *ModelInfo, error) {
+	var t T
+	mi, err := r.sess.getCore().r.get(&t)
+	if err != nil {
+		return nil, nil, err
+	}
+	q, err := r.Build()
+	return q, mi, err
+}
+
+// RawExecer 执行手写的 INSERT、UPDATE 等语句
+type RawExecer struct {
+	sess  Session
+	query string
+	args  []any
+}
+
+// RawExec 和 RawQuery 一样支持命名参数
+func RawExec(sess Session, query string, args ...any) *RawExecer {
+	return &RawExecer{
+		sess:  sess,
+		query: query,
+		args:  args,
+	}
+}
+
+func (r *RawExecer) Build() (*Query, error) {
+	return bindNamed(r.query, r.args)
+}
+
+func (r *RawExecer) Exec(ctx context.Context) sql.Result {
+	q, err := r.Build()
+	if err != nil {
+		return Result{err: err}
+	}
+	res, err := r.sess.exec(ctx, q.SQL, q.Args...)
+	return Result{err: err, res: res}
+}
+
+// bindNamed 把命名参数替换为 ?。只有 args 是一个 map[string]any 或者结构体的时候
+// 才会解析命名参数，否则原样返回
+func bindNamed(query string, args []any) (*Query, error) {
+	if len(args) != 1 {
+		return &Query{SQL: query, Args: args}, nil
+	}
+	lookup, ok := namedLookup(args[0])
+	if !ok {
+		return &Query{SQL: query, Args: args}, nil
+	}
+
+	var (
+		sb    strings.Builder
+		res   []any
+		quote rune
+	)
+	rs := []rune(query)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		if quote != 0 {
+			sb.WriteRune(r)
+			if r == '\\' && quote != '`' && i+1 < len(rs) {
+				i++
+				sb.WriteRune(rs[i])
+			} else if r == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch {
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case (r == ':' || r == '@') && i+1 < len(rs) && isNameStart(rs[i+1]) &&
+			(i == 0 || rs[i-1] != r):
+			// ::type 是类型转换，@@name 是 MySQL 的系统变量，都不是命名参数
+			j := i + 1
+			for j < len(rs) && isNamePart(rs[j]) {
+				j++
+			}
+			name := string(rs[i+1 : j])
+			val, ok := lookup(name)
+			if !ok {
+				return nil, fmt.Errorf("toy-orm: 缺少命名参数 %s", name)
+			}
+			var err error
+			res, err = bindValue(&sb, res, name, val)
+			if err != nil {
+				return nil, err
+			}
+			i = j - 1
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return &Query{SQL: sb.String(), Args: res}, nil
+}
+
+// bindValue 写入占位符，切片会展开为多个占位符，用于 IN 查询。
+// 空切片会得到 IN ()，这是一个语法错误，所以直接返回错误
+func bindValue(sb *strings.Builder, args []any, name string, val any) ([]any, error) {
+	rv := reflect.ValueOf(val)
+	if _, ok := val.(driver.Valuer); ok || rv.Kind() != reflect.Slice || rv.Type().Elem().Kind() == reflect.Uint8 {
+		sb.WriteByte('?')
+		return append(args, val), nil
+	}
+	if rv.Len() == 0 {
+		return nil, fmt.Errorf("toy-orm: 命名参数 %s 是空切片", name)
+	}
+	for i := 0; i < rv.Len(); i++ {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteByte('?')
+		args = append(args, rv.Index(i).Interface())
+	}
+	return args, nil
+}
+
+// namedLookup 返回按照名字查找参数的方法。
+// 结构体可以使用字段名或者字段名对应的列名，例如 :FirstName 或者 :first_name
+func namedLookup(arg any) (func(name string) (any, bool), bool) {
+	if m, ok := arg.(map[string]any); ok {
+		return func(name string) (any, bool) {
+			val, ok := m[name]
+			return val, ok
+		}, true
+	}
+	if _, ok := arg.(driver.Valuer); ok {
+		return nil, false
+	}
+	rv := reflect.ValueOf(arg)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct || rv.Type() == timeType {
+		return nil, false
+	}
+	typ := rv.Type()
+	return func(name string) (any, bool) {
+		for i := 0; i < typ.NumField(); i++ {
+			fd := typ.Field(i)
+			if !fd.IsExported() {
+				continue
+			}
+			cn := underscoreName(fd.Name)
+			if c := parseTag(fd.Tag.Get("orm"))["column"]; c != "" {
+				cn = c
+			}
+			if fd.Name == name || cn == name {
+				return rv.Field(i).Interface(), true
+			}
+		}
+		return nil, false
+	}, true
+}
+
+func isNameStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isNamePart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
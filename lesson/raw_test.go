@@ -0,0 +1,145 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestRawQuerier_Build(t *testing.T) {
+	testCases := []struct {
+		name     string
+		q        QueryBuilder
+		wantSQL  string
+		wantArgs []any
+		wantErr  error
+	}{
+		{
+			// 没有命名参数的时候原样返回
+			name:     "positional",
+			q:        RawQuery[TestModel](nil, "SELECT * FROM `test_model` WHERE `id` = ?", 1),
+			wantSQL:  "SELECT * FROM `test_model` WHERE `id` = ?",
+			wantArgs: []any{1},
+		},
+		{
+			name: "map",
+			q: RawQuery[TestModel](nil, "SELECT * FROM `test_model` WHERE `age` > :age AND `first_name` = @name",
+				map[string]any{"age": 18, "name": "Tom"}),
+			wantSQL:  "SELECT * FROM `test_model` WHERE `age` > ? AND `first_name` = ?",
+			wantArgs: []any{18, "Tom"},
+		},
+		{
+			// 切片展开为多个占位符
+			name: "slice",
+			q: RawQuery[TestModel](nil, "SELECT * FROM `test_model` WHERE `id` IN (:ids)",
+				map[string]any{"ids": []int64{1, 2, 3}}),
+			wantSQL:  "SELECT * FROM `test_model` WHERE `id` IN (?,?,?)",
+			wantArgs: []any{int64(1), int64(2), int64(3)},
+		},
+		{
+			name: "empty slice",
+			q: RawQuery[TestModel](nil, "SELECT * FROM `test_model` WHERE `id` IN (:ids)",
+				map[string]any{"ids": []int64{}}),
+			wantErr: errors.New("toy-orm: 命名参数 ids 是空切片"),
+		},
+		{
+			// 结构体可以使用字段名或者列名
+			name: "struct",
+			q: RawExec(nil, "UPDATE `test_model` SET `first_name` = :FirstName WHERE `id` = :id",
+				&TestModel{Id: 1, FirstName: "Tom"}),
+			wantSQL:  "UPDATE `test_model` SET `first_name` = ? WHERE `id` = ?",
+			wantArgs: []any{"Tom", int64(1)},
+		},
+		{
+			// 字符串、标识符、类型转换和系统变量里面的冒号和 @ 不是命名参数
+			name: "ignored",
+			q: RawQuery[TestModel](nil, "SELECT ':a', `@b`, 'it\\'s :c', @@version, x::int FROM `test_model` WHERE `id` = :id",
+				map[string]any{"id": 1}),
+			wantSQL:  "SELECT ':a', `@b`, 'it\\'s :c', @@version, x::int FROM `test_model` WHERE `id` = ?",
+			wantArgs: []any{1},
+		},
+		{
+			// time.Time 是一个普通的参数
+			name:     "time",
+			q:        RawQuery[TestModel](nil, "SELECT * FROM `test_model` WHERE `ctime` > :t", time.Unix(0, 0)),
+			wantSQL:  "SELECT * FROM `test_model` WHERE `ctime` > :t",
+			wantArgs: []any{time.Unix(0, 0)},
+		},
+		{
+			name:    "missing",
+			q:       RawQuery[TestModel](nil, "SELECT * FROM `test_model` WHERE `id` = :id", map[string]any{}),
+			wantErr: errors.New("toy-orm: 缺少命名参数 id"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantSQL, q.SQL)
+			assert.Equal(t, tc.wantArgs, q.Args)
+		})
+	}
+}
+
+func TestRawQuerier(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	rows := sqlmock.NewRows([]string{"id", "first_name", "age", "last_name"})
+	rows.AddRow(1, "Tom", 18, "Jerry")
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `test_model` WHERE `age` > ? LIMIT 1")).
+		WithArgs(10).WillReturnRows(rows)
+	rows = sqlmock.NewRows([]string{"id", "first_name"})
+	rows.AddRow(1, "Tom")
+	rows.AddRow(2, "Jerry")
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT `id`,`first_name` FROM `test_model` WHERE `id` IN (?,?)")).
+		WithArgs(1, 2).WillReturnRows(rows)
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `test_model` WHERE `age` < ?")).
+		WithArgs(18).WillReturnResult(sqlmock.NewResult(0, 3))
+
+	tm, err := RawQuery[TestModel](db, "SELECT * FROM `test_model` WHERE `age` > ? LIMIT 1", 10).Get(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, &TestModel{Id: 1, FirstName: "Tom", Age: 18,
+		LastName: &sql.NullString{String: "Jerry", Valid: true}}, tm)
+
+	tms, err := RawQuery[TestModel](db, "SELECT `id`,`first_name` FROM `test_model` WHERE `id` IN (:ids)",
+		map[string]any{"ids": []int{1, 2}}).GetMulti(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []*TestModel{{Id: 1, FirstName: "Tom"}, {Id: 2, FirstName: "Jerry"}}, tms)
+
+	affected, err := RawExec(db, "DELETE FROM `test_model` WHERE `age` < @age", map[string]any{"age": 18}).
+		Exec(ctx).RowsAffected()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), affected)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
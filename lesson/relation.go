@@ -0,0 +1,450 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+type relationKind int
+
+const (
+	hasOne relationKind = iota + 1
+	hasMany
+	belongsTo
+	manyToMany
+)
+
+// relation 是模型之间的关联关系，例如：
+// Orders  []*Order `orm:"hasMany;fk=user_id"`
+// Profile *Profile `orm:"hasOne;fk=user_id"`
+// User    *User    `orm:"belongsTo;fk=user_id"`
+// Roles   []*Role  `orm:"many2many=user_roles"`，也可以写成 `orm:"many2many:user_roles"`
+// hasOne 和 hasMany 的 fk 在关联模型上，belongsTo 的 fk 在当前模型上。
+// ref 是 fk 引用的列，默认是 id。
+// many2many 的 fk 和 assocFk 都在中间表上，
+// 分别引用当前模型的 ref 列和关联模型的 assocRef 列
+type relation struct {
+	kind      relationKind
+	fieldName string
+	// elem 是关联模型的结构体类型
+	elem reflect.Type
+	fk   string
+	ref  string
+
+	joinTable string
+	assocFk   string
+	assocRef  string
+}
+
+// parseRelation 解析关联字段，如果不是关联字段，返回 nil
+func parseRelation(owner reflect.Type, fd reflect.StructField, tags map[string]string) (*relation, error) {
+	rel := &relation{
+		fieldName: fd.Name,
+		fk:        tags["fk"],
+		ref:       tags["ref"],
+	}
+	typ := fd.Type
+	switch {
+	case hasTag(tags, "hasOne"):
+		rel.kind = hasOne
+	case hasTag(tags, "hasMany"):
+		rel.kind = hasMany
+		if typ.Kind() != reflect.Slice {
+			return nil, fmt.Errorf("toy-orm: 关联字段 %s 必须是切片", fd.Name)
+		}
+		typ = typ.Elem()
+	case hasTag(tags, "belongsTo"):
+		rel.kind = belongsTo
+	case hasRelationTag(tags, "many2many"):
+		rel.kind = manyToMany
+		rel.joinTable = relationTag(tags, "many2many")
+		if rel.joinTable == "" {
+			return nil, fmt.Errorf("toy-orm: 关联字段 %s 缺少中间表", fd.Name)
+		}
+		if typ.Kind() != reflect.Slice {
+			return nil, fmt.Errorf("toy-orm: 关联字段 %s 必须是切片", fd.Name)
+		}
+		typ = typ.Elem()
+	default:
+		return nil, nil
+	}
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("toy-orm: 关联字段 %s 必须是结构体或者结构体指针", fd.Name)
+	}
+	rel.elem = typ
+
+	if rel.fk == "" {
+		if rel.kind == belongsTo {
+			rel.fk = underscoreName(fd.Name) + "_id"
+		} else {
+			rel.fk = underscoreName(owner.Name()) + "_id"
+		}
+	}
+	if rel.ref == "" {
+		rel.ref = "id"
+	}
+	if rel.kind == manyToMany {
+		rel.assocFk, rel.assocRef = tags["assocFk"], tags["assocRef"]
+		if rel.assocFk == "" {
+			rel.assocFk = underscoreName(typ.Name()) + "_id"
+		}
+		if rel.assocRef == "" {
+			rel.assocRef = "id"
+		}
+	}
+	return rel, nil
+}
+
+func hasTag(tags map[string]string, key string) bool {
+	_, ok := tags[key]
+	return ok
+}
+
+// relationTag 读取关联标签的值，支持 many2many=user_roles 和 many2many:user_roles 两种写法
+func relationTag(tags map[string]string, key string) string {
+	if val, ok := tags[key]; ok {
+		return val
+	}
+	for k := range tags {
+		if strings.HasPrefix(k, key+":") {
+			return strings.TrimSpace(k[len(key)+1:])
+		}
+	}
+	return ""
+}
+
+func hasRelationTag(tags map[string]string, key string) bool {
+	if hasTag(tags, key) {
+		return true
+	}
+	for k := range tags {
+		if strings.HasPrefix(k, key+":") {
+			return true
+		}
+	}
+	return false
+}
+
+// columns 返回当前模型上用于关联的列，和关联模型上对应的列。
+// 对于 many2many 来说，是当前模型上被中间表引用的列，和关联模型上被中间表引用的列
+func (r *relation) columns() (string, string) {
+	switch r.kind {
+	case belongsTo:
+		return r.fk, r.ref
+	case manyToMany:
+		return r.ref, r.assocRef
+	default:
+		return r.ref, r.fk
+	}
+}
+
+// preload 加载 vals 的关联数据，vals 里面都是指向 mi 对应结构体的指针
+func preload(ctx context.Context, sess Session, mi *ModelInfo, vals []reflect.Value, paths []string) error {
+	if len(vals) == 0 || len(paths) == 0 {
+		return nil
+	}
+	// 按照第一段分组，这样 Orders 和 Orders.Items 只会加载一次 Orders
+	names := make([]string, 0, len(paths))
+	subPaths := make(map[string][]string, len(paths))
+	for _, p := range paths {
+		name, rest, _ := strings.Cut(p, ".")
+		if _, ok := subPaths[name]; !ok {
+			names = append(names, name)
+			subPaths[name] = nil
+		}
+		if rest != "" {
+			subPaths[name] = append(subPaths[name], rest)
+		}
+	}
+
+	for _, name := range names {
+		rel, ok := mi.relations[name]
+		if !ok {
+			return fmt.Errorf("toy-orm: 未知关联 %s", name)
+		}
+		ld, err := rel.query(ctx, sess, mi, vals)
+		if err != nil {
+			return err
+		}
+		// 先加载下一层，再把结果放进当前层，
+		// 因为关联字段可能是结构体而不是指针，放进去之后就是一个副本了
+		if err = preload(ctx, sess, ld.mi, ld.children, subPaths[name]); err != nil {
+			return err
+		}
+		for _, child := range ld.children {
+			if err = afterSelect(ctx, sess, child.Interface()); err != nil {
+				return err
+			}
+		}
+		rel.stitch(mi, vals, ld)
+	}
+	return nil
+}
+
+// loaded 是查询出来的关联数据
+type loaded struct {
+	mi       *ModelInfo
+	children []reflect.Value
+	// links 只用于 many2many，是当前模型的 key 到关联模型 key 的映射
+	links map[any][]any
+}
+
+// query 查询 vals 的关联数据
+func (r *relation) query(ctx context.Context, sess Session,
+	mi *ModelInfo, vals []reflect.Value) (*loaded, error) {
+	childMi, pfi, cfi, err := r.fields(sess.getCore(), mi)
+	if err != nil {
+		return nil, err
+	}
+	ld := &loaded{mi: childMi}
+	keys := relationKeys(vals, pfi.fieldName)
+	if r.kind == manyToMany {
+		// 先查中间表，再用中间表里面的 key 查询关联模型
+		ld.links, keys, err = r.queryLinks(ctx, sess, pfi, cfi, keys)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(keys) == 0 {
+		return ld, nil
+	}
+	ld.children, err = selectIn(ctx, sess, r.elem, childMi, cfi.fieldName, keys)
+	return ld, err
+}
+
+// queryLinks 查询 many2many 的中间表，返回 key 的映射，以及所有关联模型的 key
+func (r *relation) queryLinks(ctx context.Context, sess Session,
+	pfi, cfi *FieldInfo, keys []any) (map[any][]any, []any, error) {
+	if len(keys) == 0 {
+		return nil, nil, nil
+	}
+	b := &builder{}
+	b.sb.WriteString("SELECT ")
+	b.quote(r.fk)
+	b.sb.WriteByte(',')
+	b.quote(r.assocFk)
+	b.sb.WriteString(" FROM ")
+	b.quote(r.joinTable)
+	b.sb.WriteString(" WHERE ")
+	b.quote(r.fk)
+	b.sb.WriteString(" IN ")
+	if err := b.buildExpression(values{vals: keys}); err != nil {
+		return nil, nil, err
+	}
+	b.sb.WriteByte(';')
+
+	rows, err := sess.query(ctx, b.sb.String(), b.args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	links := make(map[any][]any, len(keys))
+	assocKeys := make([]any, 0, len(keys))
+	seen := make(map[any]struct{}, len(keys))
+	for rows.Next() {
+		// 按照两边模型上的字段类型来扫描，保证 key 可以匹配上
+		ov, tv := reflect.New(pfi.typ), reflect.New(cfi.typ)
+		if err = rows.Scan(ov.Interface(), tv.Interface()); err != nil {
+			return nil, nil, err
+		}
+		ownerKey, ok := normalizeKey(ov)
+		if !ok {
+			continue
+		}
+		tk, ok := normalizeKey(tv)
+		if !ok {
+			continue
+		}
+		links[ownerKey] = append(links[ownerKey], tk)
+		if _, has := seen[tk]; !has {
+			seen[tk] = struct{}{}
+			assocKeys = append(assocKeys, tk)
+		}
+	}
+	return links, assocKeys, rows.Err()
+}
+
+// fields 返回关联模型的元数据，以及 columns 对应的两个字段
+func (r *relation) fields(c core, mi *ModelInfo) (*ModelInfo, *FieldInfo, *FieldInfo, error) {
+	childMi, err := c.r.get(reflect.New(r.elem).Interface())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	pc, cc := r.columns()
+	pfi, ok := mi.columnMap[pc]
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("toy-orm: 关联 %s 的列 %s 不存在", r.fieldName, pc)
+	}
+	cfi, ok := childMi.columnMap[cc]
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("toy-orm: 关联 %s 的列 %s 不存在", r.fieldName, cc)
+	}
+	return childMi, pfi, cfi, nil
+}
+
+// relationKeys 收集 vals 上字段 name 的值，去重并且去掉 NULL
+func relationKeys(vals []reflect.Value, name string) []any {
+	keys := make([]any, 0, len(vals))
+	seen := make(map[any]struct{}, len(vals))
+	for _, val := range vals {
+		key, ok := normalizeKey(val.Elem().FieldByName(name))
+		if !ok {
+			continue
+		}
+		if _, ok = seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// selectIn 执行 SELECT * FROM tbl WHERE field IN (keys)，
+// 同样会加上 Scope 和软删除的条件
+func selectIn(ctx context.Context, sess Session, elem reflect.Type,
+	mi *ModelInfo, field string, keys []any) ([]reflect.Value, error) {
+	where, err := selectWhere(ctx, sess.getCore(), reflect.PtrTo(elem), mi,
+		[]Predicate{C(field).In(keys...)}, false)
+	if err != nil {
+		return nil, err
+	}
+	b := &builder{mi: mi}
+	b.sb.WriteString("SELECT * FROM ")
+	b.quote(mi.tableName)
+	if err = b.buildWhere(where); err != nil {
+		return nil, err
+	}
+	b.sb.WriteByte(';')
+
+	rows, err := sess.query(ctx, b.sb.String(), b.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	res := make([]reflect.Value, 0, len(keys))
+	for rows.Next() {
+		val := reflect.New(elem)
+		if err = sess.getCore().scanRow(rows, mi, val.Elem()); err != nil {
+			return nil, err
+		}
+		res = append(res, val)
+	}
+	return res, rows.Err()
+}
+
+// stitch 把关联数据放到 vals 对应的字段上
+func (r *relation) stitch(mi *ModelInfo, vals []reflect.Value, ld *loaded) {
+	pc, cc := r.columns()
+	pfn, cfn := mi.columnMap[pc].fieldName, ld.mi.columnMap[cc].fieldName
+	grouped := make(map[any][]reflect.Value, len(ld.children))
+	for _, child := range ld.children {
+		if key, ok := normalizeKey(child.Elem().FieldByName(cfn)); ok {
+			grouped[key] = append(grouped[key], child)
+		}
+	}
+
+	for _, val := range vals {
+		var matched []reflect.Value
+		if key, ok := normalizeKey(val.Elem().FieldByName(pfn)); ok {
+			if r.kind == manyToMany {
+				for _, tk := range ld.links[key] {
+					matched = append(matched, grouped[tk]...)
+				}
+			} else {
+				matched = grouped[key]
+			}
+		}
+		fd := val.Elem().FieldByName(r.fieldName)
+		if r.kind == hasMany || r.kind == manyToMany {
+			ptr := fd.Type().Elem().Kind() == reflect.Ptr
+			res := reflect.MakeSlice(fd.Type(), 0, len(matched))
+			for _, m := range matched {
+				if !ptr {
+					m = m.Elem()
+				}
+				res = reflect.Append(res, m)
+			}
+			fd.Set(res)
+			continue
+		}
+		switch {
+		case len(matched) == 0:
+			fd.Set(reflect.Zero(fd.Type()))
+		case fd.Kind() == reflect.Ptr:
+			fd.Set(matched[0])
+		default:
+			fd.Set(matched[0].Elem())
+		}
+	}
+}
+
+// normalizeKey 把值转换为可以比较的 key，用于关联查询和 GetByPKs 的匹配，
+// 这样 int 和 int64、*int64 和 sql.NullInt64 都能匹配上。
+// NULL 和不能作为 map key 的值返回 false
+func normalizeKey(v reflect.Value) (any, bool) {
+	for v.IsValid() {
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			return nil, false
+		}
+		if valuer, ok := v.Interface().(driver.Valuer); ok {
+			val, err := valuer.Value()
+			if err != nil {
+				return nil, false
+			}
+			v = reflect.ValueOf(val)
+			continue
+		}
+		if v.Kind() != reflect.Ptr {
+			break
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Invalid:
+		return nil, false
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if u := v.Uint(); u <= math.MaxInt64 {
+			return int64(u), true
+		}
+		return v.Uint(), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.String:
+		return v.String(), true
+	case reflect.Slice:
+		// []byte 不能作为 map 的 key
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes()), true
+		}
+		return nil, false
+	default:
+		if !v.Type().Comparable() {
+			return nil, false
+		}
+		return v.Interface(), true
+	}
+}
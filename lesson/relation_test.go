@@ -0,0 +1,320 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+type RelUser struct {
+	Id      int64
+	Name    string
+	Orders  []*RelOrder `orm:"hasMany;fk=user_id"`
+	Profile *RelProfile `orm:"hasOne;fk=user_id"`
+}
+
+type RelProfile struct {
+	Id     int64
+	UserId int64
+	Bio    string
+}
+
+type RelOrder struct {
+	Id     int64
+	UserId sql.NullInt64
+	Items  []RelItem `orm:"hasMany;fk=order_id"`
+	User   *RelUser  `orm:"belongsTo"`
+}
+
+type RelItem struct {
+	Id      int64
+	OrderId int64
+	Name    string
+}
+
+func TestSelector_Preload(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `rel_user`;")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).
+			AddRow(1, "Tom").AddRow(2, "Jerry"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `rel_order` WHERE `user_id` IN (?,?);")).
+		WithArgs(int64(1), int64(2)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).
+			AddRow(10, 1).AddRow(11, 1).AddRow(12, 2))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `rel_item` WHERE `order_id` IN (?,?,?);")).
+		WithArgs(int64(10), int64(11), int64(12)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "name"}).
+			AddRow(100, 10, "apple").AddRow(101, 12, "banana").AddRow(102, 10, "cherry"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `rel_profile` WHERE `user_id` IN (?,?);")).
+		WithArgs(int64(1), int64(2)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "bio"}).
+			AddRow(1000, 1, "hello"))
+
+	users, err := NewSelector[RelUser](db).
+		Preload("Orders", "Orders.Items", "Profile").GetMulti(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []*RelUser{
+		{
+			Id:   1,
+			Name: "Tom",
+			Orders: []*RelOrder{
+				{
+					Id:     10,
+					UserId: sql.NullInt64{Int64: 1, Valid: true},
+					Items:  []RelItem{{Id: 100, OrderId: 10, Name: "apple"}, {Id: 102, OrderId: 10, Name: "cherry"}},
+				},
+				{
+					Id:     11,
+					UserId: sql.NullInt64{Int64: 1, Valid: true},
+					Items:  []RelItem{},
+				},
+			},
+			Profile: &RelProfile{Id: 1000, UserId: 1, Bio: "hello"},
+		},
+		{
+			Id:   2,
+			Name: "Jerry",
+			Orders: []*RelOrder{
+				{
+					Id:     12,
+					UserId: sql.NullInt64{Int64: 2, Valid: true},
+					Items:  []RelItem{{Id: 101, OrderId: 12, Name: "banana"}},
+				},
+			},
+		},
+	}, users)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestSelector_PreloadBelongsTo(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `rel_order` WHERE `id` = ?;")).
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(10, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `rel_user` WHERE `id` IN (?);")).
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Tom"))
+
+	order, err := NewSelector[RelOrder](db).Where(C("Id").EQ(10)).
+		Preload("User").Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, &RelUser{Id: 1, Name: "Tom"}, order.User)
+
+	// 外键为 NULL 的时候不会发起查询
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `rel_order` WHERE `id` = ?;")).
+		WithArgs(11).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(11, nil))
+	order, err = NewSelector[RelOrder](db).Where(C("Id").EQ(11)).
+		Preload("User").Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, order.User)
+
+	// 未知关联
+	mock.ExpectQuery("SELECT .*").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(10, 1))
+	_, err = NewSelector[RelOrder](db).Preload("Invalid").Get(context.Background())
+	assert.Equal(t, errors.New("toy-orm: 未知关联 Invalid"), err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func Test_parseRelation(t *testing.T) {
+	testCases := []struct {
+		name    string
+		input   any
+		wantRel map[string]*relation
+		wantErr error
+	}{
+		{
+			name:  "default fk",
+			input: &RelOrder{},
+			wantRel: map[string]*relation{
+				"Items": {
+					kind:      hasMany,
+					fieldName: "Items",
+					elem:      reflect.TypeOf(RelItem{}),
+					fk:        "order_id",
+					ref:       "id",
+				},
+				"User": {
+					kind:      belongsTo,
+					fieldName: "User",
+					elem:      reflect.TypeOf(RelUser{}),
+					fk:        "user_id",
+					ref:       "id",
+				},
+			},
+		},
+		{
+			name: "has many not slice",
+			input: &struct {
+				Orders *RelOrder `orm:"hasMany"`
+			}{},
+			wantErr: errors.New("toy-orm: 关联字段 Orders 必须是切片"),
+		},
+		{
+			name: "many2many without join table",
+			input: &struct {
+				Roles []*M2MRole `orm:"many2many"`
+			}{},
+			wantErr: errors.New("toy-orm: 关联字段 Roles 缺少中间表"),
+		},
+		{
+			name: "many2many with colon",
+			input: &struct {
+				Id    int64
+				Roles []*M2MRole `orm:"many2many:user_roles;fk=user_id;assocFk=role_id"`
+			}{},
+			wantRel: map[string]*relation{
+				"Roles": {
+					kind:      manyToMany,
+					fieldName: "Roles",
+					elem:      reflect.TypeOf(M2MRole{}),
+					joinTable: "user_roles",
+					fk:        "user_id",
+					ref:       "id",
+					assocFk:   "role_id",
+					assocRef:  "id",
+				},
+			},
+		},
+		{
+			name: "not struct",
+			input: &struct {
+				Orders []int64 `orm:"hasMany"`
+			}{},
+			wantErr: errors.New("toy-orm: 关联字段 Orders 必须是结构体或者结构体指针"),
+		},
+	}
+	r := &registry{}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mi, err := r.register(tc.input)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantRel, mi.relations)
+		})
+	}
+}
+
+func Test_normalizeKey(t *testing.T) {
+	n := int64(1)
+	testCases := []struct {
+		name   string
+		val    any
+		want   any
+		wantOK bool
+	}{
+		{name: "int", val: 1, want: int64(1), wantOK: true},
+		{name: "pointer", val: &n, want: int64(1), wantOK: true},
+		{name: "valuer", val: sql.NullInt64{Int64: 1, Valid: true}, want: int64(1), wantOK: true},
+		{name: "null", val: sql.NullInt64{}},
+		{name: "nil pointer", val: (*int64)(nil)},
+		{name: "bytes", val: []byte("a"), want: "a", wantOK: true},
+		{name: "string", val: "a", want: "a", wantOK: true},
+		{name: "named int", val: Millis(1), want: int64(1), wantOK: true},
+		{name: "float", val: float32(1.5), want: 1.5, wantOK: true},
+		// 不能作为 map 的 key
+		{name: "slice", val: []int{1}},
+		{name: "map", val: map[string]int{}},
+		{name: "struct with slice", val: struct{ Ids []int }{}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			key, ok := normalizeKey(reflect.ValueOf(tc.val))
+			assert.Equal(t, tc.wantOK, ok)
+			assert.Equal(t, tc.want, key)
+		})
+	}
+}
+
+type M2MUser struct {
+	Id    int64
+	Name  string
+	Roles []*M2MRole `orm:"many2many=user_roles;fk=user_id;assocFk=role_id"`
+}
+
+type M2MRole struct {
+	Id   int64
+	Name string
+}
+
+func TestSelector_PreloadMany2Many(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `m2_m_user`;")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).
+			AddRow(1, "Tom").AddRow(2, "Jerry").AddRow(3, "Spike"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT `user_id`,`role_id` FROM `user_roles` WHERE `user_id` IN (?,?,?);")).
+		WithArgs(int64(1), int64(2), int64(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "role_id"}).
+			AddRow(1, 10).AddRow(1, 11).AddRow(2, 10))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `m2_m_role` WHERE `id` IN (?,?);")).
+		WithArgs(int64(10), int64(11)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).
+			AddRow(10, "admin").AddRow(11, "editor"))
+
+	users, err := NewSelector[M2MUser](db).Preload("Roles").GetMulti(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, editor := &M2MRole{Id: 10, Name: "admin"}, &M2MRole{Id: 11, Name: "editor"}
+	assert.Equal(t, []*M2MUser{
+		{Id: 1, Name: "Tom", Roles: []*M2MRole{admin, editor}},
+		{Id: 2, Name: "Jerry", Roles: []*M2MRole{admin}},
+		{Id: 3, Name: "Spike", Roles: []*M2MRole{}},
+	}, users)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,195 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"errors"
+	"reflect"
+)
+
+// Repository 封装了模型常用的增删改查，sess 可以是 DB，也可以是 Tx：
+//
+//	repo := NewRepository[User](db)
+//	err := repo.Create(ctx, &User{Name: "Tom"})
+//	users, err := repo.Find(ctx, []Predicate{C("Age").GT(18)}, FindOrderBy(Desc("Id")), FindLimit(10))
+type Repository[T any] struct {
+	sess Session
+}
+
+func NewRepository[T any](sess Session) *Repository[T] {
+	return &Repository[T]{sess: sess}
+}
+
+// WithSession 返回使用 sess 的 Repository，一般用于在事务里面执行
+func (r *Repository[T]) WithSession(sess Session) *Repository[T] {
+	return &Repository[T]{sess: sess}
+}
+
+// Create 插入一行数据。单列的自增主键为零值的时候由数据库生成，插入之后回填主键
+func (r *Repository[T]) Create(ctx context.Context, val *T) error {
+	res := NewInserter[T](r.sess).Values(val).Exec(ctx)
+	if _, err := res.RowsAffected(); err != nil {
+		return err
+	}
+	mi, err := r.model()
+	if err != nil {
+		return err
+	}
+	if len(mi.pks) != 1 || !mi.pks[0].autoIncrement {
+		return nil
+	}
+	fd := reflect.ValueOf(val).Elem().FieldByName(mi.pks[0].fieldName)
+	if !fd.IsZero() || !fd.CanInt() && !fd.CanUint() {
+		return nil
+	}
+	id, err := res.LastInsertId()
+	if err != nil || id <= 0 {
+		// 有些驱动不支持 LastInsertId，不影响插入的结果
+		return nil
+	}
+	if fd.CanInt() {
+		fd.SetInt(id)
+	} else {
+		fd.SetUint(uint64(id))
+	}
+	return nil
+}
+
+// CreateMany 使用一个 INSERT 语句插入多行数据，vals 为空的时候什么都不做
+func (r *Repository[T]) CreateMany(ctx context.Context, vals ...*T) error {
+	if len(vals) == 0 {
+		return nil
+	}
+	_, err := NewInserter[T](r.sess).Values(vals...).Exec(ctx).RowsAffected()
+	return err
+}
+
+// FindByPK 参考 GetByPK
+func (r *Repository[T]) FindByPK(ctx context.Context, pk ...any) (*T, error) {
+	return GetByPK[T](ctx, r.sess, pk...)
+}
+
+// FindOne 返回满足条件的第一行数据，没有数据的时候返回错误
+func (r *Repository[T]) FindOne(ctx context.Context, ps ...Predicate) (*T, error) {
+	return NewSelector[T](r.sess).Where(ps...).Get(ctx)
+}
+
+// FindOption 控制 Find 的排序和分页
+type FindOption func(opt *findOptions)
+
+type findOptions struct {
+	orderBy []OrderBy
+	limit   int
+	offset  int
+}
+
+func FindOrderBy(obs ...OrderBy) FindOption {
+	return func(opt *findOptions) {
+		opt.orderBy = obs
+	}
+}
+
+func FindLimit(limit int) FindOption {
+	return func(opt *findOptions) {
+		opt.limit = limit
+	}
+}
+
+// FindOffset 跳过 offset 行，没有 FindLimit 的时候读取剩下的所有数据
+func FindOffset(offset int) FindOption {
+	return func(opt *findOptions) {
+		opt.offset = offset
+	}
+}
+
+// Find 返回满足 where 的所有数据，where 为空的时候返回全部数据
+func (r *Repository[T]) Find(ctx context.Context, where []Predicate, opts ...FindOption) ([]*T, error) {
+	var opt findOptions
+	for _, o := range opts {
+		o(&opt)
+	}
+	return NewSelector[T](r.sess).Where(where...).
+		OrderBy(opt.orderBy...).Limit(opt.limit).Offset(opt.offset).GetMulti(ctx)
+}
+
+// Page 参考 PaginateOffset
+func (r *Repository[T]) Page(ctx context.Context, page, size int, where []Predicate,
+	obs ...OrderBy) (*OffsetPage[T], error) {
+	return PaginateOffset(ctx, NewSelector[T](r.sess).Where(where...).OrderBy(obs...), page, size)
+}
+
+// Update 根据主键更新除了主键、创建时间、软删除和租户之外的所有字段，返回影响的行数。
+// 有乐观锁的时候，版本号不一致会返回 ErrOptimisticLockConflict
+func (r *Repository[T]) Update(ctx context.Context, val *T) (int64, error) {
+	mi, err := r.model()
+	if err != nil {
+		return 0, err
+	}
+	rv := reflect.ValueOf(val).Elem()
+	pk := make([]any, 0, len(mi.pks))
+	for _, fi := range mi.pks {
+		pk = append(pk, fieldValue(rv, fi.fieldName))
+	}
+	p, err := pkPredicateOf(mi, pk)
+	if err != nil {
+		return 0, err
+	}
+	tenant := r.sess.getCore().tenantField
+	assigns := make([]Assignable, 0, len(mi.fields))
+	for _, name := range mi.fields {
+		fi := mi.fieldMap[name]
+		if mi.isPK(fi) || fi == mi.createTime || fi == mi.softDelete || name == tenant {
+			continue
+		}
+		assigns = append(assigns, C(name))
+	}
+	if len(assigns) == 0 {
+		return 0, errors.New("toy-orm: 没有需要更新的列")
+	}
+	return NewUpdater[T](r.sess).Update(val).Set(assigns...).Where(p).Exec(ctx).RowsAffected()
+}
+
+// Delete 根据主键删除，返回影响的行数，软删除的模型会被标记为删除
+func (r *Repository[T]) Delete(ctx context.Context, pk ...any) (int64, error) {
+	return DeleteByPK[T](ctx, r.sess, pk...).RowsAffected()
+}
+
+// Count 返回满足条件的数量
+func (r *Repository[T]) Count(ctx context.Context, ps ...Predicate) (int64, error) {
+	return count(ctx, NewSelector[T](r.sess).Where(ps...))
+}
+
+// Exists 判断是否存在满足条件的数据，只会读取一行
+func (r *Repository[T]) Exists(ctx context.Context, ps ...Predicate) (bool, error) {
+	s := NewSelector[T](r.sess).Select(Raw("1")).Where(ps...).Limit(1)
+	q, err := s.build(ctx)
+	if err != nil {
+		return false, err
+	}
+	rows, err := r.sess.query(ctx, q.SQL, q.Args...)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = rows.Close() }()
+	if rows.Next() {
+		return true, nil
+	}
+	return false, rows.Err()
+}
+
+func (r *Repository[T]) model() (*ModelInfo, error) {
+	return r.sess.getCore().r.get(new(T))
+}
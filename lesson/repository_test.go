@@ -0,0 +1,306 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"regexp"
+	"testing"
+)
+
+type RepoUser struct {
+	Id      int64 `orm:"pk;autoIncrement"`
+	Name    string
+	Age     int
+	Version int64 `orm:"version"`
+}
+
+type repositoryTestSuite struct {
+	suite.Suite
+
+	db   *DB
+	repo *Repository[RepoUser]
+}
+
+func (s *repositoryTestSuite) SetupSuite() {
+	db, err := NewDB("sqlite3", "file:repository.db?cache=shared&mode=memory")
+	if err != nil {
+		s.T().Fatal(err)
+	}
+	_, err = RawExec(db, "CREATE TABLE `repo_user`(`id` INTEGER PRIMARY KEY, "+
+		"`name` TEXT NOT NULL, `age` INTEGER NOT NULL, `version` INTEGER NOT NULL)").
+		Exec(context.Background()).RowsAffected()
+	if err != nil {
+		s.T().Fatal(err)
+	}
+	s.db = db
+	s.repo = NewRepository[RepoUser](db)
+}
+
+func (s *repositoryTestSuite) TearDownSuite() {
+	_ = s.db.Close()
+}
+
+func (s *repositoryTestSuite) TearDownTest() {
+	_, err := RawExec(s.db, "DELETE FROM `repo_user`").Exec(context.Background()).RowsAffected()
+	if err != nil {
+		s.T().Fatal(err)
+	}
+}
+
+func (s *repositoryTestSuite) TestCreate() {
+	t := s.T()
+	ctx := context.Background()
+
+	// 自增主键会被回填
+	u := &RepoUser{Name: "Tom", Age: 18}
+	assert.Nil(t, s.repo.Create(ctx, u))
+	assert.NotZero(t, u.Id)
+	res, err := s.repo.FindByPK(ctx, u.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, u, res)
+
+	// 指定了主键的时候保持不变
+	assert.Nil(t, s.repo.Create(ctx, &RepoUser{Id: 100, Name: "Jerry"}))
+	res, err = s.repo.FindByPK(ctx, 100)
+	assert.Nil(t, err)
+	assert.Equal(t, "Jerry", res.Name)
+	err = s.repo.Create(ctx, &RepoUser{Id: 100, Name: "Jerry"})
+	assert.NotNil(t, err)
+
+	assert.Nil(t, s.repo.CreateMany(ctx))
+	assert.Nil(t, s.repo.CreateMany(ctx, &RepoUser{Name: "a"}, &RepoUser{Name: "b"}))
+	cnt, err := s.repo.Count(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), cnt)
+}
+
+func (s *repositoryTestSuite) TestFind() {
+	t := s.T()
+	ctx := context.Background()
+	s.createUsers(10)
+
+	u, err := s.repo.FindOne(ctx, C("Name").EQ("user3"))
+	assert.Nil(t, err)
+	assert.Equal(t, 13, u.Age)
+	_, err = s.repo.FindOne(ctx, C("Name").EQ("nobody"))
+	assert.Equal(t, errors.New("toy-orm: 未找到数据"), err)
+	_, err = s.repo.FindByPK(ctx)
+	assert.Equal(t, errors.New("toy-orm: 主键有 1 列，传入了 0 个值"), err)
+
+	testCases := []struct {
+		name    string
+		where   []Predicate
+		opts    []FindOption
+		wantIds []int64
+	}{
+		{
+			name:    "all",
+			wantIds: []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+		},
+		{
+			name:    "where",
+			where:   []Predicate{C("Age").GT(15), C("Age").LT(18)},
+			wantIds: []int64{6, 7},
+		},
+		{
+			name:    "order by and limit",
+			opts:    []FindOption{FindOrderBy(Desc("Age")), FindLimit(3)},
+			wantIds: []int64{10, 9, 8},
+		},
+		{
+			name:    "offset",
+			where:   []Predicate{C("Age").GT(12)},
+			opts:    []FindOption{FindOrderBy(Asc("Id")), FindLimit(2), FindOffset(2)},
+			wantIds: []int64{5, 6},
+		},
+		{
+			// 没有 FindLimit 的时候使用 LIMIT -1
+			name:    "offset without limit",
+			opts:    []FindOption{FindOrderBy(Asc("Id")), FindOffset(7)},
+			wantIds: []int64{8, 9, 10},
+		},
+		{
+			name:    "empty",
+			where:   []Predicate{C("Age").GT(100)},
+			wantIds: []int64{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := s.repo.Find(ctx, tc.where, tc.opts...)
+			assert.Nil(t, err)
+			ids := make([]int64, 0, len(res))
+			for _, u := range res {
+				ids = append(ids, u.Id)
+			}
+			assert.Equal(t, tc.wantIds, ids)
+		})
+	}
+}
+
+func (s *repositoryTestSuite) TestPageAndCount() {
+	t := s.T()
+	ctx := context.Background()
+	s.createUsers(5)
+
+	page, err := s.repo.Page(ctx, 2, 2, []Predicate{C("Age").GT(10)}, Asc("Id"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), page.Total)
+	assert.Equal(t, 2, len(page.Items))
+	assert.Equal(t, int64(3), page.Items[0].Id)
+
+	cnt, err := s.repo.Count(ctx, C("Age").GT(12))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), cnt)
+
+	ok, err := s.repo.Exists(ctx, C("Name").EQ("user5"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = s.repo.Exists(ctx, C("Name").EQ("user6"))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func (s *repositoryTestSuite) TestUpdateAndDelete() {
+	t := s.T()
+	ctx := context.Background()
+	s.createUsers(2)
+
+	u, err := s.repo.FindByPK(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := *u
+	u.Name, u.Age = "Tom", 30
+	affected, err := s.repo.Update(ctx, u)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), affected)
+	res, err := s.repo.FindByPK(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, &RepoUser{Id: 1, Name: "Tom", Age: 30, Version: 2}, res)
+
+	// 版本号已经变了
+	_, err = s.repo.Update(ctx, &stale)
+	assert.Equal(t, ErrOptimisticLockConflict, err)
+
+	affected, err = s.repo.Delete(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), affected)
+	affected, err = s.repo.Delete(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), affected)
+	ok, err := s.repo.Exists(ctx, C("Id").EQ(1))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func (s *repositoryTestSuite) TestTx() {
+	t := s.T()
+	ctx := context.Background()
+
+	// 回滚之后数据不存在
+	tx, err := s.db.Begin(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := s.repo.WithSession(tx)
+	u := &RepoUser{Name: "Tom"}
+	assert.Nil(t, repo.Create(ctx, u))
+	ok, err := repo.Exists(ctx, C("Id").EQ(u.Id))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Nil(t, tx.Rollback())
+	ok, err = s.repo.Exists(ctx)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	// 提交之后数据存在
+	tx, err = s.db.Begin(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo = NewRepository[RepoUser](tx)
+	assert.Nil(t, repo.CreateMany(ctx, &RepoUser{Name: "a"}, &RepoUser{Name: "b"}))
+	u, err = repo.FindOne(ctx, C("Name").EQ("a"))
+	assert.Nil(t, err)
+	u.Age = 20
+	_, err = repo.Update(ctx, u)
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Commit())
+	cnt, err := s.repo.Count(ctx, C("Age").EQ(20))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), cnt)
+}
+
+// createUsers 插入 id 从 1 到 n 的数据，年龄是 10 + id
+func (s *repositoryTestSuite) createUsers(n int) {
+	for i := 1; i <= n; i++ {
+		err := s.repo.Create(context.Background(), &RepoUser{Name: fmt.Sprintf("user%d", i), Age: 10 + i})
+		if err != nil {
+			s.T().Fatal(err)
+		}
+	}
+}
+
+func TestRepository_SQLite(t *testing.T) {
+	suite.Run(t, &repositoryTestSuite{})
+}
+
+func TestRepository_FindOffset(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 只有 FindOffset 的时候使用方言里面不限制行数的 LIMIT
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `repo_user` LIMIT 18446744073709551615 OFFSET ?;")).
+		WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	res, err := NewRepository[RepoUser](db).Find(context.Background(), nil, FindOffset(2))
+	assert.Nil(t, err)
+	assert.Equal(t, []*RepoUser{{Id: 3}}, res)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_UpdateTenant(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB, DBWithTenant("TenantId"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 租户字段不会被更新，只能更新当前租户的数据
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `tenant_model` SET `name`=? WHERE (`id` = ?) AND (`tenant_id` = ?);")).
+		WithArgs("Tom", 1, 7).WillReturnResult(sqlmock.NewResult(0, 0))
+	affected, err := NewRepository[TenantModel](db).
+		Update(WithTenant(context.Background(), int64(7)), &TenantModel{Id: 1, TenantId: 8, Name: "Tom"})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), affected)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
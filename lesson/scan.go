@@ -0,0 +1,150 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"unsafe"
+)
+
+// scanRow 将当前行的数据写入到 val 里面，val 必须是可以取地址的结构体。
+// 优先使用生成的 FieldAccessor，其次根据配置使用 unsafe 或者反射
+func (c core) scanRow(rows *sql.Rows, meta *ModelInfo, val reflect.Value) error {
+	fds, err := columnFields(rows, meta)
+	if err != nil {
+		return err
+	}
+	return c.scanFields(rows, fds, val)
+}
+
+// columnFields 返回结果集的每一列对应的字段，同一个结果集只需要计算一次
+func columnFields(rows *sql.Rows, meta *ModelInfo) ([]*FieldInfo, error) {
+	cs, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if len(cs) > len(meta.fieldMap) {
+		return nil, errors.New("toy-orm: 列过多")
+	}
+	fds := make([]*FieldInfo, len(cs))
+	for i, col := range cs {
+		fi, ok := meta.columnMap[col]
+		if !ok {
+			return nil, fmt.Errorf("toy-orm: 非法列名 %s", col)
+		}
+		fds[i] = fi
+	}
+	return fds, nil
+}
+
+func (c core) scanFields(rows *sql.Rows, fds []*FieldInfo, val reflect.Value) error {
+	if fa, ok := val.Addr().Interface().(FieldAccessor); ok {
+		return scanAccessor(rows, fds, fa)
+	}
+	if c.useReflect {
+		return scanReflect(rows, fds, val)
+	}
+	return scanUnsafe(rows, fds, val)
+}
+
+// scanAccessor 生成的代码可以直接拿到字段的指针，不需要反射
+func scanAccessor(rows *sql.Rows, fds []*FieldInfo, fa FieldAccessor) error {
+	colValues := make([]any, len(fds))
+	for i, fi := range fds {
+		colValues[i] = fa.FieldPtr(fi.fieldName)
+	}
+	return rows.Scan(colValues...)
+}
+
+// scanUnsafe 根据字段的偏移量计算出字段的地址，直接扫描到结构体的内存里面
+func scanUnsafe(rows *sql.Rows, fds []*FieldInfo, val reflect.Value) error {
+	base := val.Addr().UnsafePointer()
+	colValues := make([]any, len(fds))
+	for i, fi := range fds {
+		ptr := unsafe.Add(base, fi.offset)
+		colValues[i] = reflect.NewAt(fi.typ, ptr).Interface()
+	}
+	return rows.Scan(colValues...)
+}
+
+// scanReflect 先扫描到新创建的值里面，再通过反射设置到字段上
+func scanReflect(rows *sql.Rows, fds []*FieldInfo, val reflect.Value) error {
+	// colValues 和 colEleValues 实质上最终都指向同一个对象
+	colValues := make([]interface{}, len(fds))
+	colEleValues := make([]reflect.Value, len(fds))
+	for i, fi := range fds {
+		v := reflect.New(fi.typ)
+		colValues[i] = v.Interface()
+		colEleValues[i] = v.Elem()
+	}
+	if err := rows.Scan(colValues...); err != nil {
+		return err
+	}
+
+	for i, fi := range fds {
+		fd := val.FieldByName(fi.fieldName)
+		fd.Set(colEleValues[i])
+	}
+	return nil
+}
+
+// mapScanner 把每一行数据读取为 map，key 是列名
+type mapScanner struct {
+	cols []string
+	// binary 为 true 的列保留 []byte，其余列的 []byte 转换为 string
+	binary []bool
+}
+
+func newMapScanner(rows *sql.Rows) (*mapScanner, error) {
+	cts, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	m := &mapScanner{
+		cols:   make([]string, len(cts)),
+		binary: make([]bool, len(cts)),
+	}
+	for i, ct := range cts {
+		m.cols[i] = ct.Name()
+		typ := strings.ToUpper(ct.DatabaseTypeName())
+		m.binary[i] = strings.Contains(typ, "BLOB") || strings.Contains(typ, "BINARY")
+	}
+	return m, nil
+}
+
+// scan 读取当前行。不同的驱动返回的类型不一样，例如 MySQL 会把字符串返回为 []byte，
+// 这里统一转换为 string，整数、浮点数和时间保持驱动返回的类型
+func (m *mapScanner) scan(rows *sql.Rows) (map[string]any, error) {
+	vals := make([]any, len(m.cols))
+	ptrs := make([]any, len(m.cols))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	if err := rows.Scan(ptrs...); err != nil {
+		return nil, err
+	}
+	res := make(map[string]any, len(m.cols))
+	for i, v := range vals {
+		if b, ok := v.([]byte); ok && !m.binary[i] {
+			v = string(b)
+		}
+		res[m.cols[i]] = v
+	}
+	return res, nil
+}
@@ -0,0 +1,118 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"database/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestScanRow(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts []DBOption
+	}{
+		{
+			name: "unsafe",
+		},
+		{
+			name: "reflect",
+			opts: []DBOption{DBUseReflect()},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = mockDB.Close() }()
+			db, err := newDB(mockDB, tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// 列的顺序和字段的顺序不一致，并且有 NULL
+			rows := sqlmock.NewRows([]string{"last_name", "age", "id", "first_name"})
+			rows.AddRow([]byte("Ming"), []byte("18"), []byte("1"), []byte("Da"))
+			rows.AddRow(nil, []byte("16"), []byte("2"), []byte("Xiao"))
+			mock.ExpectQuery("SELECT .*").WillReturnRows(rows)
+
+			res, err := NewSelector[TestModel](db).GetMulti(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, []*TestModel{
+				{
+					Id:        1,
+					FirstName: "Da",
+					Age:       18,
+					LastName:  &sql.NullString{String: "Ming", Valid: true},
+				},
+				{
+					Id:        2,
+					FirstName: "Xiao",
+					Age:       16,
+				},
+			}, res)
+		})
+	}
+}
+
+func BenchmarkScanRow(b *testing.B) {
+	testCases := []struct {
+		name string
+		opts []DBOption
+	}{
+		{
+			name: "unsafe",
+		},
+		{
+			name: "reflect",
+			opts: []DBOption{DBUseReflect()},
+		},
+	}
+	for _, tc := range testCases {
+		b.Run(tc.name, func(b *testing.B) {
+			mockDB, mock, err := sqlmock.New()
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer func() { _ = mockDB.Close() }()
+			db, err := newDB(mockDB, tc.opts...)
+			if err != nil {
+				b.Fatal(err)
+			}
+			for i := 0; i < b.N; i++ {
+				rows := sqlmock.NewRows([]string{"id", "first_name", "age", "last_name"})
+				for j := 0; j < 100; j++ {
+					rows.AddRow([]byte("1"), []byte("Da"), []byte("18"), []byte("Ming"))
+				}
+				mock.ExpectQuery("SELECT .*").WillReturnRows(rows)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, err = NewSelector[TestModel](db).GetMulti(context.Background())
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
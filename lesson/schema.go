@@ -0,0 +1,168 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"fmt"
+)
+
+// CreateTables 为 models 创建表，已经存在的表会被跳过。
+// models 必须是结构体指针，例如 db.CreateTables(ctx, &User{}, &Order{})
+func (db *DB) CreateTables(ctx context.Context, models ...any) error {
+	for _, m := range models {
+		stmts, err := db.CreateTableSQL(m)
+		if err != nil {
+			return err
+		}
+		for _, stmt := range stmts {
+			if _, err = db.exec(ctx, stmt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// CreateTableSQL 返回为 model 建表的语句。
+// 不能在 CREATE TABLE 里面声明索引的方言会额外返回 CREATE INDEX 语句
+func (db *DB) CreateTableSQL(model any) ([]string, error) {
+	mi, err := db.r.get(model)
+	if err != nil {
+		return nil, err
+	}
+	return createTableSQL(db.dialect, mi)
+}
+
+func createTableSQL(d Dialect, mi *ModelInfo) ([]string, error) {
+	b := &builder{mi: mi}
+	b.sb.WriteString("CREATE TABLE IF NOT EXISTS ")
+	b.quote(mi.tableName)
+	b.sb.WriteByte('(')
+	inlinePK := d.inlinePrimaryKey() && len(mi.pks) == 1
+	for i, fn := range mi.fields {
+		fi := mi.fieldMap[fn]
+		if i > 0 {
+			b.sb.WriteString(", ")
+		}
+		if err := b.buildColumnDef(d, fi, inlinePK); err != nil {
+			return nil, err
+		}
+	}
+	if len(mi.pks) > 0 && !inlinePK {
+		b.sb.WriteString(", PRIMARY KEY ")
+		b.buildIndexColumns(pkColumns(mi))
+	}
+	if d.inlineIndex() {
+		for _, idx := range mi.indexes {
+			b.sb.WriteString(", ")
+			if idx.unique {
+				b.sb.WriteString("UNIQUE ")
+			}
+			b.sb.WriteString("KEY ")
+			b.quote(idx.name)
+			b.sb.WriteByte(' ')
+			b.buildIndexColumns(idx.columns)
+		}
+	}
+	b.sb.WriteString(");")
+
+	res := []string{b.sb.String()}
+	if !d.inlineIndex() {
+		for _, idx := range mi.indexes {
+			res = append(res, createIndexSQL(mi.tableName, idx, true))
+		}
+	}
+	return res, nil
+}
+
+// buildColumnDef 构造列定义，例如 `id` BIGINT NOT NULL AUTO_INCREMENT
+func (b *builder) buildColumnDef(d Dialect, fi *FieldInfo, inlinePK bool) error {
+	typ, err := d.columnType(fi.typ, fi.size)
+	if err != nil {
+		return err
+	}
+	isPK := b.mi.isPK(fi)
+	b.quote(fi.columnName)
+	b.sb.WriteByte(' ')
+	b.sb.WriteString(typ)
+	if fi.notNull || isPK {
+		b.sb.WriteString(" NOT NULL")
+	}
+	if inlinePK && isPK {
+		b.sb.WriteString(" PRIMARY KEY")
+	}
+	if fi.autoIncrement {
+		// 例如 SQLite 只允许 INTEGER PRIMARY KEY 自增
+		if d.inlinePrimaryKey() && !(inlinePK && isPK) {
+			return fmt.Errorf("toy-orm: %s 只支持单列主键自增，字段 %s", d.Name(), fi.fieldName)
+		}
+		b.sb.WriteByte(' ')
+		b.sb.WriteString(d.autoIncrement())
+	}
+	if fi.dflt != nil {
+		b.sb.WriteString(" DEFAULT ")
+		b.sb.WriteString(*fi.dflt)
+	}
+	return nil
+}
+
+func (b *builder) buildIndexColumns(cols []string) {
+	b.sb.WriteByte('(')
+	for i, c := range cols {
+		if i > 0 {
+			b.sb.WriteByte(',')
+		}
+		b.quote(c)
+	}
+	b.sb.WriteByte(')')
+}
+
+// createIndexSQL 构造 CREATE INDEX 语句，MySQL 不支持 IF NOT EXISTS
+func createIndexSQL(table string, idx *index, ifNotExists bool) string {
+	b := &builder{}
+	b.sb.WriteString("CREATE ")
+	if idx.unique {
+		b.sb.WriteString("UNIQUE ")
+	}
+	b.sb.WriteString("INDEX ")
+	if ifNotExists {
+		b.sb.WriteString("IF NOT EXISTS ")
+	}
+	b.quote(idx.name)
+	b.sb.WriteString(" ON ")
+	b.quote(table)
+	b.sb.WriteByte(' ')
+	b.buildIndexColumns(idx.columns)
+	b.sb.WriteByte(';')
+	return b.sb.String()
+}
+
+func (m *ModelInfo) isPK(fi *FieldInfo) bool {
+	for _, pk := range m.pks {
+		if pk == fi {
+			return true
+		}
+	}
+	return false
+}
+
+func pkColumns(mi *ModelInfo) []string {
+	res := make([]string, 0, len(mi.pks))
+	for _, pk := range mi.pks {
+		res = append(res, pk.columnName)
+	}
+	return res
+}
@@ -0,0 +1,173 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type SchemaUser struct {
+	Id        int64  `orm:"pk;autoIncrement"`
+	Email     string `orm:"notNull;size=128;unique"`
+	FirstName string `orm:"index=idx_name"`
+	LastName  string `orm:"index=idx_name"`
+	Age       int8   `orm:"default=0"`
+	Balance   float64
+	Active    bool
+	Avatar    []byte
+	Nickname  sql.NullString
+	CreatedAt time.Time
+	DeletedAt *time.Time
+}
+
+type SchemaUserRole struct {
+	UserId int64 `orm:"pk"`
+	RoleId int64 `orm:"pk"`
+}
+
+func TestCreateTableSQL(t *testing.T) {
+	testCases := []struct {
+		name    string
+		dialect Dialect
+		model   any
+		want    []string
+		wantErr error
+	}{
+		{
+			name:    "mysql",
+			dialect: MySQL,
+			model:   &SchemaUser{},
+			want: []string{
+				"CREATE TABLE IF NOT EXISTS `schema_user`(`id` BIGINT NOT NULL AUTO_INCREMENT, " +
+					"`email` VARCHAR(128) NOT NULL, `first_name` VARCHAR(255), `last_name` VARCHAR(255), " +
+					"`age` TINYINT DEFAULT 0, `balance` DOUBLE, `active` TINYINT(1), `avatar` BLOB, " +
+					"`nickname` VARCHAR(255), `created_at` DATETIME(3), `deleted_at` DATETIME(3), " +
+					"PRIMARY KEY (`id`), UNIQUE KEY `uk_schema_user_email` (`email`), " +
+					"KEY `idx_name` (`first_name`,`last_name`));",
+			},
+		},
+		{
+			// SQLite 的索引需要单独创建
+			name:    "sqlite",
+			dialect: SQLite,
+			model:   &SchemaUser{},
+			want: []string{
+				"CREATE TABLE IF NOT EXISTS `schema_user`(`id` INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT, " +
+					"`email` TEXT NOT NULL, `first_name` TEXT, `last_name` TEXT, " +
+					"`age` INTEGER DEFAULT 0, `balance` REAL, `active` INTEGER, `avatar` BLOB, " +
+					"`nickname` TEXT, `created_at` DATETIME, `deleted_at` DATETIME);",
+				"CREATE UNIQUE INDEX IF NOT EXISTS `uk_schema_user_email` ON `schema_user` (`email`);",
+				"CREATE INDEX IF NOT EXISTS `idx_name` ON `schema_user` (`first_name`,`last_name`);",
+			},
+		},
+		{
+			// 联合主键
+			name:    "composite primary key",
+			dialect: SQLite,
+			model:   &SchemaUserRole{},
+			want: []string{
+				"CREATE TABLE IF NOT EXISTS `schema_user_role`(`user_id` INTEGER NOT NULL, " +
+					"`role_id` INTEGER NOT NULL, PRIMARY KEY (`user_id`,`role_id`));",
+			},
+		},
+		{
+			// SQLite 只有 INTEGER PRIMARY KEY 才能自增
+			name:    "sqlite auto increment without pk",
+			dialect: SQLite,
+			model: &struct {
+				Id  int64
+				Seq int64 `orm:"autoIncrement"`
+			}{},
+			wantErr: errors.New("toy-orm: sqlite3 只支持单列主键自增，字段 Seq"),
+		},
+		{
+			name:    "unsupported type",
+			dialect: MySQL,
+			model: &struct {
+				Tags []string
+			}{},
+			wantErr: errors.New("toy-orm: 不支持的列类型 []string"),
+		},
+		{
+			name:    "invalid size",
+			dialect: MySQL,
+			model: &struct {
+				Name string `orm:"size=abc"`
+			}{},
+			wantErr: errors.New("toy-orm: 字段 Name 的 size 必须是正整数"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, err := newDB(nil, DBWithDialect(tc.dialect))
+			if err != nil {
+				t.Fatal(err)
+			}
+			stmts, err := db.CreateTableSQL(tc.model)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.want, stmts)
+		})
+	}
+}
+
+func TestDB_CreateTables(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB, DBWithDialect(SQLite))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS `schema_user`").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE UNIQUE INDEX IF NOT EXISTS `uk_schema_user_email`").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE INDEX IF NOT EXISTS `idx_name`").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS `schema_user_role`").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = db.CreateTables(context.Background(), &SchemaUser{}, &SchemaUserRole{})
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestDB_CreateTables_SQLite(t *testing.T) {
+	db, err := NewDB("sqlite3", "file:schema.db?cache=shared&mode=memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.db.Close() }()
+	ctx := context.Background()
+	// 重复建表不会出错
+	for i := 0; i < 2; i++ {
+		if err = db.CreateTables(ctx, &SchemaUser{}, &SchemaUserRole{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = NewInserter[SchemaUser](db).Values(&SchemaUser{Email: "tom@example.com"}).Exec(ctx).RowsAffected()
+	assert.Nil(t, err)
+	// 唯一索引生效
+	_, err = NewInserter[SchemaUser](db).Values(&SchemaUser{Id: 2, Email: "tom@example.com"}).Exec(ctx).RowsAffected()
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,135 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrTenantMissing 表示开启了多租户，但是 context 里面没有租户信息。
+// 这种情况下查询会直接失败，而不是返回所有租户的数据
+var ErrTenantMissing = errors.New("toy-orm: context 里面没有租户信息")
+
+// Scope 根据 context 生成额外的查询条件，
+// Selector、Updater 和 Deleter 会把它 AND 到 WHERE 里面。
+// 返回 error 会中断整个操作
+type Scope func(ctx context.Context) (Predicate, error)
+
+// DBWithScope 注册作用于所有模型的 Scope
+func DBWithScope(s Scope) DBOption {
+	return func(db *DB) {
+		db.scopes = append(db.scopes, s)
+	}
+}
+
+// DBWithModelScope 注册只作用于模型 T 的 Scope
+func DBWithModelScope[T any](s Scope) DBOption {
+	typ := reflect.TypeOf(new(T))
+	return func(db *DB) {
+		if db.modelScopes == nil {
+			db.modelScopes = make(map[reflect.Type][]Scope, 4)
+		}
+		db.modelScopes[typ] = append(db.modelScopes[typ], s)
+	}
+}
+
+// DBWithTenant 开启多租户，field 是模型里面代表租户的字段名，例如 TenantId。
+// 有这个字段的模型在查询、更新和删除的时候都会加上租户条件，
+// 插入的时候会用 context 里面的租户填充这个字段
+func DBWithTenant(field string) DBOption {
+	return func(db *DB) {
+		db.tenantField = field
+	}
+}
+
+type tenantKey struct{}
+
+// WithTenant 把租户放到 context 里面
+func WithTenant(ctx context.Context, tenant any) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFrom 从 context 里面取出租户
+func TenantFrom(ctx context.Context) (any, bool) {
+	tenant := ctx.Value(tenantKey{})
+	return tenant, tenant != nil
+}
+
+type skipScopesKey struct{}
+
+// SkipScopes 跳过所有的 Scope，包括租户条件，一般用于后台管理任务
+func SkipScopes(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipScopesKey{}, true)
+}
+
+func scopesSkipped(ctx context.Context) bool {
+	skip, _ := ctx.Value(skipScopesKey{}).(bool)
+	return skip
+}
+
+// scopePredicates 计算模型需要额外加上的查询条件，typ 是模型的指针类型
+func (c core) scopePredicates(ctx context.Context, typ reflect.Type, mi *ModelInfo) ([]Predicate, error) {
+	if scopesSkipped(ctx) {
+		return nil, nil
+	}
+	var res []Predicate
+	if fi, ok := mi.fieldMap[c.tenantField]; ok {
+		tenant, ok := TenantFrom(ctx)
+		if !ok {
+			return nil, ErrTenantMissing
+		}
+		res = append(res, C(fi.fieldName).EQ(tenant))
+	}
+	for _, ss := range [][]Scope{c.scopes, c.modelScopes[typ]} {
+		for _, s := range ss {
+			p, err := s(ctx)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, p)
+		}
+	}
+	return res, nil
+}
+
+// fillTenant 用 context 里面的租户填充 val 的租户字段，
+// 如果 val 已经有了不同的租户，返回错误
+func (c core) fillTenant(ctx context.Context, val reflect.Value, mi *ModelInfo) error {
+	fi, ok := mi.fieldMap[c.tenantField]
+	if !ok || scopesSkipped(ctx) {
+		return nil
+	}
+	tenant, ok := TenantFrom(ctx)
+	if !ok {
+		return ErrTenantMissing
+	}
+	tv := reflect.ValueOf(tenant)
+	if !tv.CanConvert(fi.typ) {
+		return fmt.Errorf("toy-orm: 租户 %v 不能赋值给字段 %s", tenant, fi.fieldName)
+	}
+	tv = tv.Convert(fi.typ)
+	fd := val.FieldByName(fi.fieldName)
+	if fd.IsZero() {
+		fd.Set(tv)
+		return nil
+	}
+	if fd.Interface() != tv.Interface() {
+		return fmt.Errorf("toy-orm: 数据的租户 %v 和 context 里面的租户 %v 不一致", fd.Interface(), tenant)
+	}
+	return nil
+}
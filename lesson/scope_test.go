@@ -0,0 +1,175 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type TenantModel struct {
+	Id       int64
+	TenantId int64
+	Name     string
+}
+
+func TestScope(t *testing.T) {
+	mockDB, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB,
+		DBWithTenant("TenantId"),
+		DBWithModelScope[TestModel](func(ctx context.Context) (Predicate, error) {
+			return C("Age").GT(18), nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenantCtx := WithTenant(context.Background(), int64(7))
+
+	testCases := []struct {
+		name string
+		ctx  context.Context
+		q    interface {
+			build(ctx context.Context) (*Query, error)
+		}
+		wantSQL  string
+		wantArgs []any
+		wantErr  error
+	}{
+		{
+			name:     "select",
+			ctx:      tenantCtx,
+			q:        NewSelector[TenantModel](db).Where(C("Id").EQ(1)),
+			wantSQL:  "SELECT * FROM `tenant_model` WHERE (`id` = ?) AND (`tenant_id` = ?);",
+			wantArgs: []any{1, int64(7)},
+		},
+		{
+			// 缺少租户信息的时候直接失败
+			name:    "select without tenant",
+			ctx:     context.Background(),
+			q:       NewSelector[TenantModel](db).Where(C("Id").EQ(1)),
+			wantErr: ErrTenantMissing,
+		},
+		{
+			// 后台任务跳过所有的 Scope
+			name:     "skip scopes",
+			ctx:      SkipScopes(context.Background()),
+			q:        NewSelector[TenantModel](db).Where(C("Id").EQ(1)),
+			wantSQL:  "SELECT * FROM `tenant_model` WHERE `id` = ?;",
+			wantArgs: []any{1},
+		},
+		{
+			name:     "update",
+			ctx:      tenantCtx,
+			q:        NewUpdater[TenantModel](db).Set(Assign("Name", "Tom")),
+			wantSQL:  "UPDATE `tenant_model` SET `name`=? WHERE `tenant_id` = ?;",
+			wantArgs: []any{"Tom", int64(7)},
+		},
+		{
+			// 没有 Set 的时候不会更新租户字段
+			name:     "update all columns",
+			ctx:      tenantCtx,
+			q:        NewUpdater[TenantModel](db).Update(&TenantModel{Id: 1, TenantId: 8, Name: "Tom"}),
+			wantSQL:  "UPDATE `tenant_model` SET `id`=?,`name`=? WHERE `tenant_id` = ?;",
+			wantArgs: []any{int64(1), "Tom", int64(7)},
+		},
+		{
+			name:    "update tenant",
+			ctx:     tenantCtx,
+			q:       NewUpdater[TenantModel](db).Set(Assign("TenantId", 8)),
+			wantErr: errors.New("toy-orm: 不能更新租户字段 TenantId"),
+		},
+		{
+			// 后台任务可以修改租户
+			name:     "update tenant skip scopes",
+			ctx:      SkipScopes(context.Background()),
+			q:        NewUpdater[TenantModel](db).Set(Assign("TenantId", 8)),
+			wantSQL:  "UPDATE `tenant_model` SET `tenant_id`=?;",
+			wantArgs: []any{8},
+		},
+		{
+			name:     "delete",
+			ctx:      tenantCtx,
+			q:        NewDeleter[TenantModel](db).Where(C("Id").EQ(1)),
+			wantSQL:  "DELETE FROM `tenant_model` WHERE (`id` = ?) AND (`tenant_id` = ?);",
+			wantArgs: []any{1, int64(7)},
+		},
+		{
+			// 没有租户字段的模型不受影响，但是会使用它自己的 Scope
+			name:     "model scope",
+			ctx:      context.Background(),
+			q:        NewSelector[TestModel](db).Where(C("Id").EQ(1)),
+			wantSQL:  "SELECT * FROM `test_model` WHERE (`id` = ?) AND (`age` > ?);",
+			wantArgs: []any{1, 18},
+		},
+		{
+			// 插入的时候自动填充租户
+			name:     "insert",
+			ctx:      tenantCtx,
+			q:        NewInserter[TenantModel](db).Values(&TenantModel{Id: 1, Name: "Tom"}),
+			wantSQL:  "INSERT INTO `tenant_model`(`id`,`tenant_id`,`name`) VALUES(?,?,?);",
+			wantArgs: []any{int64(1), int64(7), "Tom"},
+		},
+		{
+			name:    "insert without tenant",
+			ctx:     context.Background(),
+			q:       NewInserter[TenantModel](db).Values(&TenantModel{Id: 1, Name: "Tom"}),
+			wantErr: ErrTenantMissing,
+		},
+		{
+			// 不能插入别的租户的数据
+			name:    "insert another tenant",
+			ctx:     tenantCtx,
+			q:       NewInserter[TenantModel](db).Values(&TenantModel{Id: 1, TenantId: 8, Name: "Tom"}),
+			wantErr: errors.New("toy-orm: 数据的租户 8 和 context 里面的租户 7 不一致"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.q.build(tc.ctx)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantSQL, q.SQL)
+			assert.Equal(t, tc.wantArgs, q.Args)
+		})
+	}
+}
+
+func TestScope_Error(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB, DBWithScope(func(ctx context.Context) (Predicate, error) {
+		return Predicate{}, errors.New("no permission")
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Scope 返回错误的时候不会发起查询
+	_, err = NewSelector[TestModel](db).Get(context.Background())
+	assert.Equal(t, errors.New("no permission"), err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -17,19 +17,26 @@ package lesson
 import (
 	"context"
 	"errors"
-	"fmt"
 	"reflect"
-	"strings"
 )
 
 type Selector[T any] struct {
+	builder
 	sess Session
-	sb   strings.Builder
-	args []any
-	mi   *ModelInfo
 
-	tbl   string
-	where []Predicate
+	columns  []Selectable
+	tbl      string
+	where    []Predicate
+	groupBy  []Column
+	orderBy  []OrderBy
+	limit    int
+	offset   int
+	unscoped bool
+	preloads []string
+
+	// example 是 WhereExample 传入的样例，为 nil 的时候不使用
+	example    any
+	exampleOpt exampleOptions
 }
 
 func NewSelector[T any](sess Session) *Selector[T] {
@@ -38,39 +45,155 @@ func NewSelector[T any](sess Session) *Selector[T] {
 	}
 }
 
+// Select 指定查询的列，默认是 SELECT *。
+// 例如 Select(C("Age"), Count("Id").As("cnt"), Raw("MAX(`id`) - MIN(`id`)"))
+func (s *Selector[T]) Select(cols ...Selectable) *Selector[T] {
+	s.columns = cols
+	return s
+}
+
+func (s *Selector[T]) GroupBy(cols ...Column) *Selector[T] {
+	s.groupBy = cols
+	return s
+}
+
+// OrderBy 例如 OrderBy(Desc("Age"), Asc("Id"))
+func (s *Selector[T]) OrderBy(obs ...OrderBy) *Selector[T] {
+	s.orderBy = obs
+	return s
+}
+
+// Limit 小于等于 0 的时候不限制
+func (s *Selector[T]) Limit(limit int) *Selector[T] {
+	s.limit = limit
+	return s
+}
+
+// Offset 小于等于 0 的时候不跳过，没有 Limit 的时候使用方言里面不限制行数的写法
+func (s *Selector[T]) Offset(offset int) *Selector[T] {
+	s.offset = offset
+	return s
+}
+
 func (s *Selector[T]) Where(ps ...Predicate) *Selector[T] {
 	s.where = ps
 	return s
 }
 
+// WhereExample 把 val 里面的非零值字段转换为相等条件，和 Where 的条件用 AND 连接，例如：
+//
+//	NewSelector[User](db).WhereExample(&User{Name: "Tom"}, ExampleLike("Name"), ExampleWithZero("Age"))
+//
+// 构造的是 WHERE `name` LIKE ? AND `age` = ?，参数是 "%Tom%" 和 0。
+// 值是 NULL 的字段使用 IS NULL，val 为 nil 的时候不加任何条件
+func (s *Selector[T]) WhereExample(val *T, opts ...ExampleOption) *Selector[T] {
+	// 直接赋值的话 s.example 是一个不等于 nil 的 any
+	if val == nil {
+		s.example = nil
+		return s
+	}
+	s.example = val
+	s.exampleOpt = exampleOptions{}
+	for _, o := range opts {
+		o(&s.exampleOpt)
+	}
+	return s
+}
+
+// Unscoped 查询的时候包含已经被软删除的数据
+func (s *Selector[T]) Unscoped() *Selector[T] {
+	s.unscoped = true
+	return s
+}
+
+// Preload 在查询之后加载关联数据，例如 Preload("Orders", "Orders.Items")。
+// 每一个关联只会额外发起一次 WHERE fk IN (...) 的查询
+func (s *Selector[T]) Preload(paths ...string) *Selector[T] {
+	s.preloads = append(s.preloads, paths...)
+	return s
+}
+
 func (s *Selector[T]) Build() (*Query, error) {
+	return s.build(context.Background())
+}
+
+// build 构造查询，ctx 用于计算 Scope
+func (s *Selector[T]) build(ctx context.Context) (*Query, error) {
 	var (
 		t   T
 		err error
 	)
-	s.mi, err = s.sess.registry().get(&t)
+	s.reset()
+	c := s.sess.getCore()
+	s.mi, err = c.r.get(&t)
 	if err != nil {
 		return nil, err
 	}
-	s.sb.WriteString("SELECT * FROM ")
+	s.sb.WriteString("SELECT ")
+	if err = s.buildColumns(); err != nil {
+		return nil, err
+	}
+	s.sb.WriteString(" FROM ")
 	if s.tbl == "" {
-		s.sb.WriteByte('`')
-		s.sb.WriteString(s.mi.tableName)
-		s.sb.WriteByte('`')
+		s.quote(s.mi.tableName)
 	} else {
 		s.sb.WriteString(s.tbl)
 	}
 
 	// 构造 WHERE
-	if len(s.where) > 0 {
-		s.sb.WriteString(" WHERE ")
-		p := s.where[0]
-		for i := 1; i < len(s.where); i++ {
-			p = p.And(s.where[i])
-		}
-		if err := s.buildExpression(p); err != nil {
+	where := s.where
+	if s.example != nil {
+		ps, err := examplePredicates(s.mi, reflect.ValueOf(s.example).Elem(), s.exampleOpt)
+		if err != nil {
 			return nil, err
 		}
+		where = append(where[:len(where):len(where)], ps...)
+	}
+	where, err = selectWhere(ctx, c, reflect.TypeOf(&t), s.mi, where, s.unscoped)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.buildWhere(where); err != nil {
+		return nil, err
+	}
+	if len(s.groupBy) > 0 {
+		s.sb.WriteString(" GROUP BY ")
+		for i, c := range s.groupBy {
+			if i > 0 {
+				s.sb.WriteByte(',')
+			}
+			if err = s.buildColumn(c.name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(s.orderBy) > 0 {
+		s.sb.WriteString(" ORDER BY ")
+		for i, ob := range s.orderBy {
+			if i > 0 {
+				s.sb.WriteByte(',')
+			}
+			if err = s.buildColumn(ob.name); err != nil {
+				return nil, err
+			}
+			if ob.desc {
+				s.sb.WriteString(" DESC")
+			} else {
+				s.sb.WriteString(" ASC")
+			}
+		}
+	}
+	if s.limit > 0 {
+		s.sb.WriteString(" LIMIT ")
+		s.addArg(s.limit)
+	} else if s.offset > 0 {
+		// 数据库不支持没有 LIMIT 的 OFFSET
+		s.sb.WriteString(" LIMIT ")
+		s.sb.WriteString(c.dialect.noLimit())
+	}
+	if s.offset > 0 {
+		s.sb.WriteString(" OFFSET ")
+		s.addArg(s.offset)
 	}
 
 	s.sb.WriteString(";")
@@ -80,56 +203,57 @@ func (s *Selector[T]) Build() (*Query, error) {
 	}, nil
 }
 
-func (s *Selector[T]) buildExpression(e Expression) error {
-	if e == nil {
+func (s *Selector[T]) buildColumns() error {
+	if len(s.columns) == 0 {
+		s.sb.WriteByte('*')
 		return nil
 	}
-	switch exp := e.(type) {
-	case Column:
-		fi, ok := s.mi.fieldMap[exp.name]
-		if !ok {
-			return fmt.Errorf("toy-orm: 非法列名 %s", exp.name)
+	for i, col := range s.columns {
+		if i > 0 {
+			s.sb.WriteByte(',')
 		}
-		s.sb.WriteByte('`')
-		s.sb.WriteString(fi.columnName)
-		s.sb.WriteByte('`')
-	case value:
-		s.addArg(exp.val)
-	case Predicate:
-		_, lp := exp.left.(Predicate)
-		if lp {
+		var alias string
+		switch c := col.(type) {
+		case Column:
+			if err := s.buildColumn(c.name); err != nil {
+				return err
+			}
+			alias = c.alias
+		case Aggregate:
+			s.sb.WriteString(c.fn)
 			s.sb.WriteByte('(')
-		}
-		if err := s.buildExpression(exp.left); err != nil {
-			return err
-		}
-		if lp {
+			if c.arg == "*" {
+				s.sb.WriteByte('*')
+			} else if err := s.buildColumn(c.arg); err != nil {
+				return err
+			}
 			s.sb.WriteByte(')')
+			alias = c.alias
+		case RawExpr:
+			if err := s.buildExpression(c); err != nil {
+				return err
+			}
 		}
-
-		s.sb.WriteByte(' ')
-		s.sb.WriteString(exp.op.String())
-		s.sb.WriteByte(' ')
-
-		_, rp := exp.right.(Predicate)
-		if rp {
-			s.sb.WriteByte('(')
+		if alias != "" {
+			s.sb.WriteString(" AS ")
+			s.quote(alias)
 		}
-		if err := s.buildExpression(exp.right); err != nil {
-			return err
-		}
-		if rp {
-			s.sb.WriteByte(')')
-		}
-	default:
-		return fmt.Errorf("toy-web: 不支持的表达式 %v", exp)
 	}
 	return nil
 }
 
-func (s *Selector[T]) addArg(val interface{}) {
-	s.sb.WriteByte('?')
-	s.args = append(s.args, val)
+// selectWhere 在用户的查询条件之后加上 Scope 和软删除的条件，typ 是模型的指针类型
+func selectWhere(ctx context.Context, c core, typ reflect.Type, mi *ModelInfo,
+	where []Predicate, unscoped bool) ([]Predicate, error) {
+	scopes, err := c.scopePredicates(ctx, typ, mi)
+	if err != nil {
+		return nil, err
+	}
+	res := append(where[:len(where):len(where)], scopes...)
+	if sd := mi.softDelete; sd != nil && !unscoped {
+		res = append(res, C(sd.fieldName).IsNull())
+	}
+	return res, nil
 }
 
 func (s *Selector[T]) From(tbl string) *Selector[T] {
@@ -138,58 +262,203 @@ func (s *Selector[T]) From(tbl string) *Selector[T] {
 }
 
 func (s *Selector[T]) Get(ctx context.Context) (*T, error) {
-	q, err := s.Build()
+	q, err := s.build(ctx)
 	if err != nil {
 		return nil, err
 	}
-	rows, err := s.sess.query(ctx, q.SQL, q.Args...)
+	qc := s.queryCache()
+	if qc == nil {
+		return getOne[T](ctx, s.sess, s.mi, q, s.preloads)
+	}
+	res, err := cachedQuery[T](ctx, qc, s.mi.tableName, true, q, func() ([]*T, error) {
+		tp, err := getOne[T](ctx, s.sess, s.mi, q, nil)
+		if err != nil {
+			return nil, err
+		}
+		return []*T{tp}, nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	if !rows.Next() {
+	return res[0], nil
+}
+
+func (s *Selector[T]) GetMulti(ctx context.Context) ([]*T, error) {
+	q, err := s.build(ctx)
+	if err != nil {
+		return nil, err
+	}
+	qc := s.queryCache()
+	if qc == nil {
+		return getMulti[T](ctx, s.sess, s.mi, q, s.preloads)
+	}
+	return cachedQuery[T](ctx, qc, s.mi.tableName, false, q, func() ([]*T, error) {
+		return getMulti[T](ctx, s.sess, s.mi, q, nil)
+	})
+}
+
+// queryCache 返回可以使用的查询缓存。事务里面的查询可能读到没有提交的数据，
+// Preload 和 From 涉及别的表，它们的修改不会让缓存失效，所以都不使用缓存
+func (s *Selector[T]) queryCache() *queryCache {
+	if _, ok := s.sess.(*Tx); ok || len(s.preloads) > 0 || s.tbl != "" {
+		return nil
+	}
+	return s.sess.getCore().qcache
+}
+
+// GetMap 返回第一行数据，key 是列名
+func (s *Selector[T]) GetMap(ctx context.Context) (map[string]any, error) {
+	res, err := s.getMaps(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(res) == 0 {
 		return nil, errors.New("toy-orm: 未找到数据")
 	}
+	return res[0], nil
+}
 
-	tp := new(T)
-	meta, err := s.sess.registry().get(tp)
+// GetMultiMap 返回所有数据，key 是列名。不需要列在 T 里面，
+// 所以可以用来读取聚合函数或者 From 指定的别的表的列
+func (s *Selector[T]) GetMultiMap(ctx context.Context) ([]map[string]any, error) {
+	return s.getMaps(ctx, false)
+}
+
+func (s *Selector[T]) getMaps(ctx context.Context, first bool) ([]map[string]any, error) {
+	q, err := s.build(ctx)
 	if err != nil {
 		return nil, err
 	}
-	cs, err := rows.Columns()
+	rows, err := s.sess.query(ctx, q.SQL, q.Args...)
 	if err != nil {
 		return nil, err
 	}
-	if len(cs) > len(meta.fieldMap) {
-		return nil, errors.New("toy-orm: 列过多")
+	defer func() { _ = rows.Close() }()
+	ms, err := newMapScanner(rows)
+	if err != nil {
+		return nil, err
 	}
-
-	// TODO 性能优化
-	// colValues 和 colEleValues 实质上最终都指向同一个对象
-	colValues := make([]interface{}, len(cs))
-	colEleValues := make([]reflect.Value, len(cs))
-	for i, c := range cs {
-		cm, ok := meta.columnMap[c]
-		if !ok {
-			return nil, fmt.Errorf("toy-orm: 非法列名 %s", c)
+	res := make([]map[string]any, 0, 8)
+	for rows.Next() {
+		m, err := ms.scan(rows)
+		if err != nil {
+			return nil, err
 		}
-		val := reflect.New(cm.typ)
-		colValues[i] = val.Interface()
-		colEleValues[i] = val.Elem()
+		res = append(res, m)
+		if first {
+			break
+		}
+	}
+	return res, rows.Err()
+}
+
+// SelectInto 把 s 的查询结果映射为 D 而不是 T，D 的字段和列的对应关系和模型一样。
+// 适用于 Select 了聚合函数，或者 From 指定了 JOIN 的场景，例如：
+//
+//	SelectInto[AgeStat](NewSelector[User](db).Select(C("Age"), Count("Id").As("cnt")).GroupBy(C("Age")))
+func SelectInto[D any, T any](s *Selector[T]) Querier[D] {
+	return &intoQuerier[D, T]{s: s}
+}
+
+type intoQuerier[D any, T any] struct {
+	s *Selector[T]
+}
+
+func (q *intoQuerier[D, T]) Get(ctx context.Context) (*D, error) {
+	query, mi, err := q.build(ctx)
+	if err != nil {
+		return nil, err
 	}
-	if err = rows.Scan(colValues...); err != nil {
+	return getOne[D](ctx, q.s.sess, mi, query, nil)
+}
+
+func (q *intoQuerier[D, T]) GetMulti(ctx context.Context) ([]*D, error) {
+	query, mi, err := q.build(ctx)
+	if err != nil {
 		return nil, err
 	}
+	return getMulti[D](ctx, q.s.sess, mi, query, nil)
+}
 
-	val := reflect.ValueOf(tp).Elem()
-	for i, c := range cs {
-		cm := meta.columnMap[c]
-		fd := val.FieldByName(cm.fieldName)
-		fd.Set(colEleValues[i])
+func (q *intoQuerier[D, T]) build(ctx context.Context) (*Query, *ModelInfo, error) {
+	query, err := q.s.build(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	mi, err := q.s.sess.getCore().r.get(new(D))
+	return query, mi, err
+}
+
+// getOne 执行查询，把第一行数据映射为 T，然后加载关联数据并调用钩子
+func getOne[T any](ctx context.Context, sess Session, mi *ModelInfo, q *Query, preloads []string) (*T, error) {
+	rows, err := sess.query(ctx, q.SQL, q.Args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("toy-orm: 未找到数据")
+	}
+
+	tp := new(T)
+	if err = sess.getCore().scanRow(rows, mi, reflect.ValueOf(tp).Elem()); err != nil {
+		return nil, err
+	}
+	// 关闭 rows 之后才能在同一个连接上加载关联数据
+	_ = rows.Close()
+	err = preload(ctx, sess, mi, []reflect.Value{reflect.ValueOf(tp)}, preloads)
+	if err != nil {
+		return nil, err
+	}
+	if err = afterSelect(ctx, sess, tp); err != nil {
+		return nil, err
 	}
 	return tp, nil
 }
 
-func (s *Selector[T]) GetMulti(ctx context.Context) ([]*T, error) {
-	// TODO implement me
-	panic("implement me")
+// getMulti 执行查询，把所有数据映射为 T，然后加载关联数据并调用钩子
+func getMulti[T any](ctx context.Context, sess Session, mi *ModelInfo, q *Query, preloads []string) ([]*T, error) {
+	rows, err := sess.query(ctx, q.SQL, q.Args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	fds, err := columnFields(rows, mi)
+	if err != nil {
+		return nil, err
+	}
+	c := sess.getCore()
+	res := make([]*T, 0, 8)
+	for rows.Next() {
+		tp := new(T)
+		if err = c.scanFields(rows, fds, reflect.ValueOf(tp).Elem()); err != nil {
+			return nil, err
+		}
+		res = append(res, tp)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	// 全部数据都读取完毕之后再加载关联数据和调用钩子，
+	// 避免在同一个连接上发起查询的时候，rows 还没有关闭
+	_ = rows.Close()
+	if len(preloads) > 0 {
+		vals := make([]reflect.Value, 0, len(res))
+		for _, tp := range res {
+			vals = append(vals, reflect.ValueOf(tp))
+		}
+		if err = preload(ctx, sess, mi, vals, preloads); err != nil {
+			return nil, err
+		}
+	}
+	for _, tp := range res {
+		if err = afterSelect(ctx, sess, tp); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
 }
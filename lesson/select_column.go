@@ -0,0 +1,77 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+// Selectable 是可以出现在 SELECT 后面的东西，
+// 也就是 Column、Aggregate 和 RawExpr
+type Selectable interface {
+	selectable()
+}
+
+// Aggregate 是聚合函数，例如 AVG(`age`)
+type Aggregate struct {
+	fn    string
+	arg   string
+	alias string
+}
+
+func (Aggregate) selectable() {}
+
+func (a Aggregate) As(alias string) Aggregate {
+	a.alias = alias
+	return a
+}
+
+func Avg(field string) Aggregate {
+	return Aggregate{fn: "AVG", arg: field}
+}
+
+func Sum(field string) Aggregate {
+	return Aggregate{fn: "SUM", arg: field}
+}
+
+func Max(field string) Aggregate {
+	return Aggregate{fn: "MAX", arg: field}
+}
+
+func Min(field string) Aggregate {
+	return Aggregate{fn: "MIN", arg: field}
+}
+
+// Count 例如 Count("Id")，Count("*") 代表 COUNT(*)
+func Count(field string) Aggregate {
+	return Aggregate{fn: "COUNT", arg: field}
+}
+
+// RawExpr 是原样拼接到 SQL 里面的表达式，args 是表达式里面的参数。
+// 可以用在 SELECT 后面，也可以通过 AsPredicate 用在 WHERE 里面
+type RawExpr struct {
+	raw  string
+	args []any
+}
+
+// Raw 例如 Raw("`o`.`amount` * ?", 2)
+func Raw(expr string, args ...any) RawExpr {
+	return RawExpr{raw: expr, args: args}
+}
+
+func (RawExpr) expr() {}
+
+func (RawExpr) selectable() {}
+
+// AsPredicate 例如 Where(Raw("`age` BETWEEN ? AND ?", 18, 30).AsPredicate())
+func (r RawExpr) AsPredicate() Predicate {
+	return Predicate{left: r}
+}
@@ -105,6 +105,134 @@ func TestSelector_Build(t *testing.T) {
 			wantSQL:  "SELECT * FROM test_db.test_model WHERE  NOT (`age` > ?);",
 			wantArgs: []any{18},
 		},
+		{
+			// 指定列
+			name:    "columns",
+			q:       NewSelector[TestModel](db).Select(C("Id"), C("FirstName").As("name")),
+			wantSQL: "SELECT `id`,`first_name` AS `name` FROM `test_model`;",
+		},
+		{
+			// 聚合函数和 GROUP BY
+			name: "aggregate",
+			q: NewSelector[TestModel](db).Select(C("Age"), Count("*").As("cnt"), Avg("Id")).
+				Where(C("Age").GT(18)).GroupBy(C("Age")),
+			wantSQL:  "SELECT `age`,COUNT(*) AS `cnt`,AVG(`id`) FROM `test_model` WHERE `age` > ? GROUP BY `age`;",
+			wantArgs: []any{18},
+		},
+		{
+			// 原生表达式，参数在 WHERE 的参数前面
+			name: "raw",
+			q: NewSelector[TestModel](db).Select(Raw("`age` + ?", 1)).
+				Where(Raw("`age` BETWEEN ? AND ?", 18, 30).AsPredicate(), C("Id").GT(1)),
+			wantSQL:  "SELECT `age` + ? FROM `test_model` WHERE (`age` BETWEEN ? AND ?) AND (`id` > ?);",
+			wantArgs: []any{1, 18, 30, 1},
+		},
+		{
+			// ORDER BY 和 LIMIT
+			name: "order by and limit",
+			q: NewSelector[TestModel](db).Where(C("Age").GT(18)).
+				OrderBy(Desc("Age"), Asc("Id")).Limit(10),
+			wantSQL:  "SELECT * FROM `test_model` WHERE `age` > ? ORDER BY `age` DESC,`id` ASC LIMIT ?;",
+			wantArgs: []any{18, 10},
+		},
+		{
+			name:     "offset",
+			q:        NewSelector[TestModel](db).OrderBy(Asc("Id")).Limit(10).Offset(20),
+			wantSQL:  "SELECT * FROM `test_model` ORDER BY `id` ASC LIMIT ? OFFSET ?;",
+			wantArgs: []any{10, 20},
+		},
+		{
+			name:     "offset without limit",
+			q:        NewSelector[TestModel](db).Offset(20),
+			wantSQL:  "SELECT * FROM `test_model` LIMIT 18446744073709551615 OFFSET ?;",
+			wantArgs: []any{20},
+		},
+		{
+			// 非零值字段转换为相等条件，和 Where 用 AND 连接
+			name: "example",
+			q: NewSelector[TestModel](db).Where(C("Id").GT(1)).
+				WhereExample(&TestModel{FirstName: "Tom", LastName: &sql.NullString{String: "Jerry", Valid: true}}),
+			wantSQL:  "SELECT * FROM `test_model` WHERE ((`id` > ?) AND (`first_name` = ?)) AND (`last_name` = ?);",
+			wantArgs: []any{1, "Tom", &sql.NullString{String: "Jerry", Valid: true}},
+		},
+		{
+			name: "example with zero and like",
+			q: NewSelector[TestModel](db).
+				WhereExample(&TestModel{FirstName: "Tom"}, ExampleWithZero("Age"), ExampleLike()),
+			wantSQL:  "SELECT * FROM `test_model` WHERE (`first_name` LIKE ?) AND (`age` = ?);",
+			wantArgs: []any{"%Tom%", int8(0)},
+		},
+		{
+			// 软删除的条件在最后
+			name:     "example soft delete",
+			q:        NewSelector[SoftDeleteModel](db).WhereExample(&SoftDeleteModel{Id: 1}),
+			wantSQL:  "SELECT * FROM `soft_delete_model` WHERE (`id` = ?) AND (`deleted_at` IS NULL);",
+			wantArgs: []any{int64(1)},
+		},
+		{
+			// NULL 使用 IS NULL
+			name: "example null",
+			q: NewSelector[TestModel](db).
+				WhereExample(&TestModel{Id: 1, LastName: &sql.NullString{}}),
+			wantSQL:  "SELECT * FROM `test_model` WHERE (`id` = ?) AND (`last_name` IS NULL);",
+			wantArgs: []any{int64(1)},
+		},
+		{
+			name:     "example nil pointer with zero",
+			q:        NewSelector[TestModel](db).WhereExample(&TestModel{Id: 1}, ExampleWithZero("LastName")),
+			wantSQL:  "SELECT * FROM `test_model` WHERE (`id` = ?) AND (`last_name` IS NULL);",
+			wantArgs: []any{int64(1)},
+		},
+		{
+			name:     "example nil",
+			q:        NewSelector[TestModel](db).Where(C("Id").EQ(1)).WhereExample(nil),
+			wantSQL:  "SELECT * FROM `test_model` WHERE `id` = ?;",
+			wantArgs: []any{1},
+		},
+		{
+			name:    "example empty",
+			q:       NewSelector[TestModel](db).WhereExample(&TestModel{}),
+			wantSQL: "SELECT * FROM `test_model`;",
+		},
+		{
+			name:    "example invalid zero field",
+			q:       NewSelector[TestModel](db).WhereExample(&TestModel{}, ExampleWithZero("Invalid")),
+			wantErr: errors.New("toy-orm: 非法列名 Invalid"),
+		},
+		{
+			name:    "example like non-string",
+			q:       NewSelector[TestModel](db).WhereExample(&TestModel{}, ExampleLike("Age")),
+			wantErr: errors.New("toy-orm: 字段 Age 不是字符串，不能使用 LIKE"),
+		},
+		{
+			name:    "invalid order by column",
+			q:       NewSelector[TestModel](db).OrderBy(Asc("Invalid")),
+			wantErr: errors.New("toy-orm: 非法列名 Invalid"),
+		},
+		{
+			name:    "invalid aggregate column",
+			q:       NewSelector[TestModel](db).Select(Max("Invalid")),
+			wantErr: errors.New("toy-orm: 非法列名 Invalid"),
+		},
+		{
+			// 软删除的模型会自动过滤已经删除的数据
+			name:     "soft delete",
+			q:        NewSelector[SoftDeleteModel](db).Where(C("Id").EQ(1)),
+			wantSQL:  "SELECT * FROM `soft_delete_model` WHERE (`id` = ?) AND (`deleted_at` IS NULL);",
+			wantArgs: []any{1},
+		},
+		{
+			name:    "soft delete without where",
+			q:       NewSelector[SoftDeleteModel](db),
+			wantSQL: "SELECT * FROM `soft_delete_model` WHERE `deleted_at` IS NULL;",
+		},
+		{
+			// 包含已经删除的数据
+			name:     "unscoped",
+			q:        NewSelector[SoftDeleteModel](db).Where(C("Id").EQ(1)).Unscoped(),
+			wantSQL:  "SELECT * FROM `soft_delete_model` WHERE `id` = ?;",
+			wantArgs: []any{1},
+		},
 		{
 			// 使用非法列名
 			name: "invalid column",
@@ -205,3 +333,165 @@ func TestSelector_Get(t *testing.T) {
 		})
 	}
 }
+
+func TestSelector_GetMulti(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name     string
+		query    string
+		mockErr  error
+		mockRows *sqlmock.Rows
+		wantErr  error
+		wantVal  []*TestModel
+	}{
+		{
+			// 查询返回错误
+			name:    "query error",
+			mockErr: errors.New("invalid query"),
+			wantErr: errors.New("invalid query"),
+			query:   "SELECT .*",
+		},
+		{
+			// 没有数据不是错误
+			name:     "no row",
+			query:    "SELECT .*",
+			mockRows: sqlmock.NewRows([]string{"id"}),
+			wantVal:  []*TestModel{},
+		},
+		{
+			name:  "invalid column",
+			query: "SELECT .*",
+			mockRows: func() *sqlmock.Rows {
+				res := sqlmock.NewRows([]string{"id", "invalid_column"})
+				res.AddRow([]byte("1"), []byte("nothing"))
+				return res
+			}(),
+			wantErr: errors.New("toy-orm: 非法列名 invalid_column"),
+		},
+		{
+			name:  "multiple rows",
+			query: "SELECT .*",
+			mockRows: func() *sqlmock.Rows {
+				res := sqlmock.NewRows([]string{"id", "first_name", "age", "last_name"})
+				res.AddRow([]byte("1"), []byte("Da"), []byte("18"), []byte("Ming"))
+				res.AddRow([]byte("2"), []byte("Xiao"), []byte("16"), nil)
+				return res
+			}(),
+			wantVal: []*TestModel{
+				{
+					Id:        1,
+					FirstName: "Da",
+					Age:       18,
+					LastName:  &sql.NullString{String: "Ming", Valid: true},
+				},
+				{
+					Id:        2,
+					FirstName: "Xiao",
+					Age:       16,
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		exp := mock.ExpectQuery(tc.query)
+		if tc.mockErr != nil {
+			exp.WillReturnError(tc.mockErr)
+		} else {
+			exp.WillReturnRows(tc.mockRows)
+		}
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := NewSelector[TestModel](db).GetMulti(context.Background())
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantVal, res)
+		})
+	}
+}
+
+type AgeStat struct {
+	Age int8
+	Cnt int64
+}
+
+func TestSelector_Projection(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	mock.ExpectQuery("SELECT `age`,COUNT\\(\\*\\) AS `cnt` FROM `test_model` GROUP BY `age`;").
+		WillReturnRows(sqlmock.NewRows([]string{"age", "cnt"}).AddRow(18, 2).AddRow(20, 1))
+	stats, err := SelectInto[AgeStat](NewSelector[TestModel](db).
+		Select(C("Age"), Count("*").As("cnt")).GroupBy(C("Age"))).GetMulti(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []*AgeStat{{Age: 18, Cnt: 2}, {Age: 20, Cnt: 1}}, stats)
+
+	// MySQL 会把字符串返回为 []byte
+	mock.ExpectQuery("SELECT (.+) FROM `test_model`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "first_name", "total"}).
+			AddRow(int64(1), []byte("Tom"), 3.5).
+			AddRow(int64(2), nil, 1.0))
+	maps, err := NewSelector[TestModel](db).From("`test_model` JOIN `order` ON `order`.`user_id` = `test_model`.`id`").
+		Select(C("Id"), C("FirstName"), Raw("SUM(`order`.`amount`) AS `total`")).GetMultiMap(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]any{
+		{"id": int64(1), "first_name": "Tom", "total": 3.5},
+		{"id": int64(2), "first_name": nil, "total": 1.0},
+	}, maps)
+
+	mock.ExpectQuery("SELECT (.+) FROM `test_model`").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	_, err = NewSelector[TestModel](db).GetMap(ctx)
+	assert.Equal(t, errors.New("toy-orm: 未找到数据"), err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestSelector_GetMap_SQLite(t *testing.T) {
+	db, err := NewDB("sqlite3", "file:get_map.db?cache=shared&mode=memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	ctx := context.Background()
+	_, err = RawExec(db, "CREATE TABLE `blob_model`(`id` INTEGER PRIMARY KEY, `name` TEXT, `data` BLOB);").
+		Exec(ctx).RowsAffected()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = RawExec(db, "INSERT INTO `blob_model` VALUES(1, 'Tom', x'0102');").Exec(ctx).RowsAffected()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// BLOB 保留 []byte
+	m, err := NewSelector[BlobModel](db).GetMap(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]any{"id": int64(1), "name": "Tom", "data": []byte{1, 2}}, m)
+}
+
+type BlobModel struct {
+	Id   int64
+	Name string
+	Data []byte
+}
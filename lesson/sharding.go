@@ -0,0 +1,554 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Shard 是一个分片，DB 是 ShardingDB 里面数据源的名字
+type Shard struct {
+	DB string
+	// Table 为空的时候使用模型的表名，也就是只分库不分表
+	Table string
+}
+
+// ShardingAlgorithm 根据分片键的值计算分片
+type ShardingAlgorithm interface {
+	// Key 是分片键的字段名
+	Key() string
+	Shard(val any) (Shard, error)
+}
+
+// HashSharding 按照分片键的哈希值分散到 DBCount 个库，每个库 TableCount 张表。
+// 整数使用本身的值，字符串使用 FNV-1a。
+// 例如 DBPattern 是 "user_db_%d"，TablePattern 是 "user_tab_%d"，
+// 数量为 1 的时候 Pattern 原样使用
+type HashSharding struct {
+	Field        string
+	DBPattern    string
+	DBCount      int
+	TablePattern string
+	TableCount   int
+}
+
+func (h HashSharding) Key() string {
+	return h.Field
+}
+
+func (h HashSharding) Shard(val any) (Shard, error) {
+	n, err := shardingHash(val)
+	if err != nil {
+		return Shard{}, err
+	}
+	dbs, tables := uint64(1), uint64(1)
+	if h.DBCount > 1 {
+		dbs = uint64(h.DBCount)
+	}
+	if h.TableCount > 1 {
+		tables = uint64(h.TableCount)
+	}
+	idx := n % (dbs * tables)
+	return Shard{
+		DB:    shardName(h.DBPattern, dbs, idx/tables),
+		Table: shardName(h.TablePattern, tables, idx%tables),
+	}, nil
+}
+
+func shardName(pattern string, cnt uint64, idx uint64) string {
+	if cnt == 1 {
+		return pattern
+	}
+	return fmt.Sprintf(pattern, idx)
+}
+
+func shardingHash(val any) (uint64, error) {
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return uint64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv.Uint(), nil
+	case reflect.String:
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(rv.String()))
+		return uint64(h.Sum32()), nil
+	default:
+		return 0, fmt.Errorf("toy-orm: 分片键不支持类型 %T", val)
+	}
+}
+
+// RangeSharding 按照分片键的范围分片，分片键必须是整数。
+// Ranges 按照 Upper 升序排列，值落在第一个 Upper 比它大的分片里面
+type RangeSharding struct {
+	Field  string
+	Ranges []ShardRange
+}
+
+type ShardRange struct {
+	// Upper 是上界，不包含
+	Upper int64
+	Shard Shard
+}
+
+func (r RangeSharding) Key() string {
+	return r.Field
+}
+
+func (r RangeSharding) Shard(val any) (Shard, error) {
+	var n int64
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = int64(rv.Uint())
+	default:
+		return Shard{}, fmt.Errorf("toy-orm: 分片键不支持类型 %T", val)
+	}
+	for _, rg := range r.Ranges {
+		if n < rg.Upper {
+			return rg.Shard, nil
+		}
+	}
+	return Shard{}, fmt.Errorf("toy-orm: 分片键的值 %v 不在任何分片里面", val)
+}
+
+// ShardingDB 管理分库分表的数据源，数据源可以是 DB，也可以是 ClusterDB
+type ShardingDB struct {
+	dbs        map[string]Session
+	algorithms map[reflect.Type]ShardingAlgorithm
+}
+
+type ShardingOption func(*ShardingDB)
+
+func NewShardingDB(dbs map[string]Session, opts ...ShardingOption) (*ShardingDB, error) {
+	if len(dbs) == 0 {
+		return nil, errors.New("toy-orm: 没有数据源")
+	}
+	res := &ShardingDB{
+		dbs:        dbs,
+		algorithms: make(map[reflect.Type]ShardingAlgorithm, 4),
+	}
+	for _, o := range opts {
+		o(res)
+	}
+	return res, nil
+}
+
+// ShardingWithModel 指定模型的分片规则，model 是结构体指针，例如 &User{}
+func ShardingWithModel(model any, algo ShardingAlgorithm) ShardingOption {
+	return func(db *ShardingDB) {
+		db.algorithms[reflect.TypeOf(model)] = algo
+	}
+}
+
+func (s *ShardingDB) session(name string) (Session, error) {
+	sess, ok := s.dbs[name]
+	if !ok {
+		return nil, fmt.Errorf("toy-orm: 数据源 %s 不存在", name)
+	}
+	return sess, nil
+}
+
+// model 返回模型的元数据和分片规则，val 是结构体指针
+func (s *ShardingDB) model(val any) (*ModelInfo, ShardingAlgorithm, error) {
+	algo, ok := s.algorithms[reflect.TypeOf(val)]
+	if !ok {
+		return nil, nil, fmt.Errorf("toy-orm: 模型 %s 没有分片规则", reflect.TypeOf(val).Elem().Name())
+	}
+	var mi *ModelInfo
+	for _, sess := range s.dbs {
+		var err error
+		if mi, err = sess.getCore().r.get(val); err != nil {
+			return nil, nil, err
+		}
+		break
+	}
+	if _, ok = mi.fieldMap[algo.Key()]; !ok {
+		return nil, nil, fmt.Errorf("toy-orm: 非法列名 %s", algo.Key())
+	}
+	return mi, algo, nil
+}
+
+// shardsOf 根据分片键的等值条件和 IN 条件找出需要查询的分片，
+// ok 为 false 表示没有能够确定分片的条件
+func shardsOf(algo ShardingAlgorithm, where []Predicate) (res []Shard, ok bool, err error) {
+	if len(where) == 0 {
+		return nil, false, nil
+	}
+	p := where[0]
+	for i := 1; i < len(where); i++ {
+		p = p.And(where[i])
+	}
+	return shardsOfPredicate(algo, p)
+}
+
+func shardsOfPredicate(algo ShardingAlgorithm, p Predicate) ([]Shard, bool, error) {
+	switch p.op {
+	case opEQ:
+		col, ok := p.left.(Column)
+		val, vok := p.right.(value)
+		if !ok || !vok || col.name != algo.Key() {
+			return nil, false, nil
+		}
+		sd, err := algo.Shard(val.val)
+		return []Shard{sd}, err == nil, err
+	case opIN:
+		col, ok := p.left.(Column)
+		vals, vok := p.right.(values)
+		if !ok || !vok || col.name != algo.Key() {
+			return nil, false, nil
+		}
+		res := make([]Shard, 0, len(vals.vals))
+		for _, val := range vals.vals {
+			sd, err := algo.Shard(val)
+			if err != nil {
+				return nil, false, err
+			}
+			res = unionShards(res, []Shard{sd})
+		}
+		return res, true, nil
+	case opAND, opOR:
+		lp, lok := p.left.(Predicate)
+		rp, rok := p.right.(Predicate)
+		if !lok || !rok {
+			return nil, false, nil
+		}
+		l, lok, err := shardsOfPredicate(algo, lp)
+		if err != nil {
+			return nil, false, err
+		}
+		r, rok, err := shardsOfPredicate(algo, rp)
+		if err != nil {
+			return nil, false, err
+		}
+		if p.op == opOR {
+			// 任何一边没有分片键，都可能命中所有的分片
+			if !lok || !rok {
+				return nil, false, nil
+			}
+			return unionShards(l, r), true, nil
+		}
+		switch {
+		case lok && rok:
+			return intersectShards(l, r), true, nil
+		case lok:
+			return l, true, nil
+		default:
+			return r, rok, nil
+		}
+	}
+	return nil, false, nil
+}
+
+func unionShards(l, r []Shard) []Shard {
+	for _, sd := range r {
+		if !containsShard(l, sd) {
+			l = append(l, sd)
+		}
+	}
+	return l
+}
+
+func intersectShards(l, r []Shard) []Shard {
+	res := make([]Shard, 0, len(l))
+	for _, sd := range l {
+		if containsShard(r, sd) {
+			res = append(res, sd)
+		}
+	}
+	return res
+}
+
+func containsShard(shards []Shard, sd Shard) bool {
+	for _, s := range shards {
+		if s == sd {
+			return true
+		}
+	}
+	return false
+}
+
+// ShardingSelector 在分片上执行查询。查询条件里面必须有分片键的等值条件或者 IN 条件，
+// 命中多个分片的时候并发查询，然后在内存里面按照 OrderBy 排序，再应用 Offset 和 Limit
+type ShardingSelector[T any] struct {
+	db      *ShardingDB
+	where   []Predicate
+	orderBy []OrderBy
+	limit   int
+	offset  int
+}
+
+func NewShardingSelector[T any](db *ShardingDB) *ShardingSelector[T] {
+	return &ShardingSelector[T]{db: db}
+}
+
+func (s *ShardingSelector[T]) Where(ps ...Predicate) *ShardingSelector[T] {
+	s.where = ps
+	return s
+}
+
+func (s *ShardingSelector[T]) OrderBy(obs ...OrderBy) *ShardingSelector[T] {
+	s.orderBy = obs
+	return s
+}
+
+func (s *ShardingSelector[T]) Limit(limit int) *ShardingSelector[T] {
+	s.limit = limit
+	return s
+}
+
+func (s *ShardingSelector[T]) Offset(offset int) *ShardingSelector[T] {
+	s.offset = offset
+	return s
+}
+
+func (s *ShardingSelector[T]) Get(ctx context.Context) (*T, error) {
+	res, err := s.getMulti(ctx, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(res) == 0 {
+		return nil, errors.New("toy-orm: 未找到数据")
+	}
+	return res[0], nil
+}
+
+func (s *ShardingSelector[T]) GetMulti(ctx context.Context) ([]*T, error) {
+	return s.getMulti(ctx, s.limit)
+}
+
+func (s *ShardingSelector[T]) getMulti(ctx context.Context, limit int) ([]*T, error) {
+	mi, algo, err := s.db.model(new(T))
+	if err != nil {
+		return nil, err
+	}
+	shards, ok, err := shardsOf(algo, s.where)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("toy-orm: 查询条件里面没有分片键 %s", algo.Key())
+	}
+	for _, ob := range s.orderBy {
+		fi, ok := mi.fieldMap[ob.name]
+		if !ok {
+			return nil, fmt.Errorf("toy-orm: 非法列名 %s", ob.name)
+		}
+		if len(shards) > 1 && !sortable(fi.typ) {
+			return nil, fmt.Errorf("toy-orm: 不支持按照字段 %s 合并排序", ob.name)
+		}
+	}
+
+	// 只有一个分片的时候直接在数据库里面分页，
+	// 否则每个分片都要读取 offset + limit 行，合并之后再分页
+	fetch, offset := limit, s.offset
+	if len(shards) > 1 {
+		offset = 0
+		if limit > 0 {
+			fetch = limit + s.offset
+		}
+	}
+	// 先找到所有的数据源，避免出错返回的时候还有查询在执行
+	sesses := make([]Session, 0, len(shards))
+	for _, sd := range shards {
+		sess, err := s.db.session(sd.DB)
+		if err != nil {
+			return nil, err
+		}
+		sesses = append(sesses, sess)
+	}
+	results := make([][]*T, len(shards))
+	errs := make([]error, len(shards))
+	var wg sync.WaitGroup
+	for i, sd := range shards {
+		sel := NewSelector[T](sesses[i]).Where(s.where...).OrderBy(s.orderBy...).Limit(fetch).Offset(offset)
+		if sd.Table != "" {
+			sel.From("`" + sd.Table + "`")
+		}
+		wg.Add(1)
+		go func(i int, sel *Selector[T]) {
+			defer wg.Done()
+			results[i], errs[i] = sel.GetMulti(ctx)
+		}(i, sel)
+	}
+	wg.Wait()
+	for _, err = range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(shards) == 1 {
+		return results[0], nil
+	}
+
+	res := make([]*T, 0, 8)
+	for _, r := range results {
+		res = append(res, r...)
+	}
+	if len(s.orderBy) > 0 {
+		sort.SliceStable(res, func(i, j int) bool {
+			vi, vj := reflect.ValueOf(res[i]).Elem(), reflect.ValueOf(res[j]).Elem()
+			for _, ob := range s.orderBy {
+				c := compareValue(reflect.ValueOf(fieldValue(vi, ob.name)), reflect.ValueOf(fieldValue(vj, ob.name)))
+				if c != 0 {
+					return (c < 0) != ob.desc
+				}
+			}
+			return false
+		})
+	}
+	if s.offset >= len(res) {
+		return []*T{}, nil
+	}
+	res = res[s.offset:]
+	if limit > 0 && limit < len(res) {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+// sortable 判断能不能在内存里面按照 typ 类型的字段排序
+func sortable(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.String:
+		return true
+	}
+	return typ == timeType
+}
+
+// compareValue 比较两个 sortable 的值，返回 -1、0 或者 1
+func compareValue(a, b reflect.Value) int {
+	var less, greater bool
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		less, greater = a.Int() < b.Int(), a.Int() > b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		less, greater = a.Uint() < b.Uint(), a.Uint() > b.Uint()
+	case reflect.Float32, reflect.Float64:
+		less, greater = a.Float() < b.Float(), a.Float() > b.Float()
+	case reflect.String:
+		less, greater = a.String() < b.String(), a.String() > b.String()
+	default:
+		ta, tb := a.Interface().(time.Time), b.Interface().(time.Time)
+		less, greater = ta.Before(tb), ta.After(tb)
+	}
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// ShardingInserter 按照分片键把数据分组，插入到各自的分片里面。
+// 不同分片上的插入不在同一个事务里面，失败的时候已经插入的分片不会回滚
+type ShardingInserter[T any] struct {
+	db     *ShardingDB
+	values []*T
+}
+
+func NewShardingInserter[T any](db *ShardingDB) *ShardingInserter[T] {
+	return &ShardingInserter[T]{db: db}
+}
+
+func (i *ShardingInserter[T]) Values(vals ...*T) *ShardingInserter[T] {
+	i.values = vals
+	return i
+}
+
+func (i *ShardingInserter[T]) Exec(ctx context.Context) sql.Result {
+	if len(i.values) == 0 {
+		return Result{err: errors.New("toy-orm: 插入0行")}
+	}
+	_, algo, err := i.db.model(new(T))
+	if err != nil {
+		return Result{err: err}
+	}
+	shards := make([]Shard, 0, 4)
+	groups := make(map[Shard][]*T, 4)
+	for _, val := range i.values {
+		sd, err := algo.Shard(fieldValue(reflect.ValueOf(val).Elem(), algo.Key()))
+		if err != nil {
+			return Result{err: err}
+		}
+		if _, ok := groups[sd]; !ok {
+			shards = append(shards, sd)
+		}
+		groups[sd] = append(groups[sd], val)
+	}
+	res := shardingResult{res: make([]sql.Result, 0, len(shards))}
+	for _, sd := range shards {
+		sess, err := i.db.session(sd.DB)
+		if err != nil {
+			res.err = err
+			return res
+		}
+		ins := NewInserter[T](sess).Values(groups[sd]...)
+		ins.tbl = sd.Table
+		r := ins.Exec(ctx).(Result)
+		if r.err != nil {
+			res.err = r.err
+			return res
+		}
+		res.res = append(res.res, r.res)
+	}
+	return res
+}
+
+// shardingResult 合并多个分片的执行结果
+type shardingResult struct {
+	err error
+	res []sql.Result
+}
+
+func (r shardingResult) LastInsertId() (int64, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if len(r.res) != 1 {
+		return 0, errors.New("toy-orm: 插入了多个分片，不支持 LastInsertId")
+	}
+	return r.res[0].LastInsertId()
+}
+
+// RowsAffected 是所有分片的影响行数之和
+func (r shardingResult) RowsAffected() (int64, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	var sum int64
+	for _, res := range r.res {
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		sum += n
+	}
+	return sum, nil
+}
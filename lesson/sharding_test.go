@@ -0,0 +1,254 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type ShardUser struct {
+	Id   int64
+	Name string
+	Age  int
+}
+
+var shardUserAlgo = HashSharding{
+	Field:        "Id",
+	DBPattern:    "user_db_%d",
+	DBCount:      2,
+	TablePattern: "user_tab_%d",
+	TableCount:   2,
+}
+
+func TestHashSharding(t *testing.T) {
+	testCases := []struct {
+		name    string
+		algo    HashSharding
+		val     any
+		want    Shard
+		wantErr error
+	}{
+		{
+			// 7 % 4 = 3，第 1 个库的第 1 张表
+			name: "int",
+			algo: shardUserAlgo,
+			val:  int64(7),
+			want: Shard{DB: "user_db_1", Table: "user_tab_1"},
+		},
+		{
+			name: "uint",
+			algo: shardUserAlgo,
+			val:  uint8(2),
+			want: Shard{DB: "user_db_1", Table: "user_tab_0"},
+		},
+		{
+			// 只分库
+			name: "db only",
+			algo: HashSharding{Field: "Name", DBPattern: "db_%d", DBCount: 3},
+			val:  "Tom",
+			want: Shard{DB: "db_1"},
+		},
+		{
+			name:    "invalid type",
+			algo:    shardUserAlgo,
+			val:     1.5,
+			wantErr: errors.New("toy-orm: 分片键不支持类型 float64"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sd, err := tc.algo.Shard(tc.val)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.want, sd)
+		})
+	}
+}
+
+func TestRangeSharding(t *testing.T) {
+	algo := RangeSharding{
+		Field: "Id",
+		Ranges: []ShardRange{
+			{Upper: 1000, Shard: Shard{DB: "db_0"}},
+			{Upper: 2000, Shard: Shard{DB: "db_1"}},
+		},
+	}
+	sd, err := algo.Shard(999)
+	assert.Nil(t, err)
+	assert.Equal(t, Shard{DB: "db_0"}, sd)
+	sd, err = algo.Shard(uint(1000))
+	assert.Nil(t, err)
+	assert.Equal(t, Shard{DB: "db_1"}, sd)
+	_, err = algo.Shard(int64(2000))
+	assert.Equal(t, errors.New("toy-orm: 分片键的值 2000 不在任何分片里面"), err)
+	_, err = algo.Shard("1")
+	assert.Equal(t, errors.New("toy-orm: 分片键不支持类型 string"), err)
+}
+
+func TestShardsOf(t *testing.T) {
+	s := func(db, tbl int) Shard {
+		return Shard{DB: fmt.Sprintf("user_db_%d", db), Table: fmt.Sprintf("user_tab_%d", tbl)}
+	}
+	testCases := []struct {
+		name   string
+		where  []Predicate
+		want   []Shard
+		wantOK bool
+	}{
+		{
+			name:   "eq",
+			where:  []Predicate{C("Age").GT(18), C("Id").EQ(5)},
+			want:   []Shard{s(0, 1)},
+			wantOK: true,
+		},
+		{
+			name:   "in",
+			where:  []Predicate{C("Id").In(1, 5, 2)},
+			want:   []Shard{s(0, 1), s(1, 0)},
+			wantOK: true,
+		},
+		{
+			// AND 取交集
+			name:   "and",
+			where:  []Predicate{C("Id").In(1, 2), C("Id").EQ(5)},
+			want:   []Shard{s(0, 1)},
+			wantOK: true,
+		},
+		{
+			name:   "empty intersection",
+			where:  []Predicate{C("Id").EQ(1).And(C("Id").EQ(2))},
+			want:   []Shard{},
+			wantOK: true,
+		},
+		{
+			// OR 取并集
+			name:   "or",
+			where:  []Predicate{C("Id").EQ(1).Or(C("Id").EQ(3))},
+			want:   []Shard{s(0, 1), s(1, 1)},
+			wantOK: true,
+		},
+		{
+			name:  "or without key",
+			where: []Predicate{C("Id").EQ(1).Or(C("Age").EQ(3))},
+		},
+		{
+			name:  "not",
+			where: []Predicate{Not(C("Id").EQ(1))},
+		},
+		{
+			name:  "other column",
+			where: []Predicate{C("Age").EQ(1)},
+		},
+		{
+			name: "no where",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, ok, err := shardsOf(shardUserAlgo, tc.where)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.wantOK, ok)
+			assert.Equal(t, tc.want, res)
+		})
+	}
+}
+
+func TestSharding_SQLite(t *testing.T) {
+	ctx := context.Background()
+	dbs := make(map[string]Session, 2)
+	for i := 0; i < 2; i++ {
+		db, err := NewDB("sqlite3", fmt.Sprintf("file:user_db_%d.db?cache=shared&mode=memory", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = db.Close() }()
+		for j := 0; j < 2; j++ {
+			_, err = RawExec(db, fmt.Sprintf("CREATE TABLE `user_tab_%d`(`id` INTEGER PRIMARY KEY, `name` TEXT, `age` INTEGER)", j)).
+				Exec(ctx).RowsAffected()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		dbs[fmt.Sprintf("user_db_%d", i)] = db
+	}
+	sdb, err := NewShardingDB(dbs, ShardingWithModel(&ShardUser{}, shardUserAlgo))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users := make([]*ShardUser, 0, 8)
+	for i := 1; i <= 8; i++ {
+		users = append(users, &ShardUser{Id: int64(i), Name: fmt.Sprintf("user%d", i), Age: 20 + i%3})
+	}
+	affected, err := NewShardingInserter[ShardUser](sdb).Values(users...).Exec(ctx).RowsAffected()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(8), affected)
+	_, err = NewShardingInserter[ShardUser](sdb).Values(&ShardUser{Id: 9}, &ShardUser{Id: 10}).Exec(ctx).LastInsertId()
+	assert.Equal(t, errors.New("toy-orm: 插入了多个分片，不支持 LastInsertId"), err)
+
+	// id 为 3 和 7 的数据在 user_db_1.user_tab_1 里面
+	cnt, err := NewSelector[ShardUser](dbs["user_db_1"]).From("`user_tab_1`").
+		Select(Count("*").As("cnt")).GetMap(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), cnt["cnt"])
+
+	// 单个分片
+	u, err := NewShardingSelector[ShardUser](sdb).Where(C("Id").EQ(6)).Get(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, &ShardUser{Id: 6, Name: "user6", Age: 20}, u)
+
+	// 多个分片合并之后排序和分页：年龄倒序，id 升序
+	// (22, 2) (22, 5) (22, 8) (21, 1) (21, 4) (21, 7) (20, 3) (20, 6)
+	res, err := NewShardingSelector[ShardUser](sdb).Where(C("Id").In(1, 2, 3, 4, 5, 6, 7, 8)).
+		OrderBy(Desc("Age"), Asc("Id")).Offset(2).Limit(4).GetMulti(ctx)
+	assert.Nil(t, err)
+	ids := make([]int64, 0, len(res))
+	for _, u := range res {
+		ids = append(ids, u.Id)
+	}
+	assert.Equal(t, []int64{8, 1, 4, 7}, ids)
+
+	u, err = NewShardingSelector[ShardUser](sdb).Where(C("Id").In(3, 4, 6).Or(C("Id").EQ(1))).
+		OrderBy(Asc("Age"), Desc("Id")).Get(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(6), u.Id)
+
+	// 没有分片键的查询会被拒绝
+	_, err = NewShardingSelector[ShardUser](sdb).Where(C("Age").GT(18)).GetMulti(ctx)
+	assert.Equal(t, errors.New("toy-orm: 查询条件里面没有分片键 Id"), err)
+	_, err = NewShardingSelector[ShardUser](sdb).Where(C("Id").EQ(1).And(C("Id").EQ(2))).Get(ctx)
+	assert.Equal(t, errors.New("toy-orm: 未找到数据"), err)
+	_, err = NewShardingSelector[TestModel](sdb).Where(C("Id").EQ(1)).Get(ctx)
+	assert.Equal(t, errors.New("toy-orm: 模型 TestModel 没有分片规则"), err)
+
+	// 没有 Limit 的时候 Offset 在合并之后应用
+	res, err = NewShardingSelector[ShardUser](sdb).Where(C("Id").In(1, 2, 3, 4)).
+		OrderBy(Asc("Id")).Offset(3).GetMulti(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(res))
+	assert.Equal(t, int64(4), res[0].Id)
+
+	// 有一个数据源不存在的时候不会发起任何查询
+	partial, err := NewShardingDB(map[string]Session{"user_db_0": dbs["user_db_0"]},
+		ShardingWithModel(&ShardUser{}, shardUserAlgo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewShardingSelector[ShardUser](partial).Where(C("Id").In(1, 2)).GetMulti(ctx)
+	assert.Equal(t, errors.New("toy-orm: 数据源 user_db_1 不存在"), err)
+}
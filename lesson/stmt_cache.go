@@ -0,0 +1,135 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// StmtCacheStats 是预编译语句缓存的统计信息
+type StmtCacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	// Size 是当前缓存的语句数量
+	Size int
+}
+
+// stmtCache 是以 SQL 为 key 的 LRU 缓存。
+// 被淘汰的语句如果还有人在用，会等到最后一个人用完之后再关闭
+type stmtCache struct {
+	db       *sql.DB
+	capacity int
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+	stats StmtCacheStats
+}
+
+type stmtEntry struct {
+	query string
+	stmt  *sql.Stmt
+	// refs 是正在使用这个语句的数量
+	refs    int
+	evicted bool
+}
+
+func newStmtCache(db *sql.DB, capacity int) *stmtCache {
+	return &stmtCache{
+		db:       db,
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element, capacity),
+	}
+}
+
+// get 返回 query 对应的语句，用完之后必须调用 release
+func (c *stmtCache) get(ctx context.Context, query string) (*stmtEntry, error) {
+	c.mu.Lock()
+	if elem, ok := c.items[query]; ok {
+		c.ll.MoveToFront(elem)
+		e := elem.Value.(*stmtEntry)
+		e.refs++
+		c.stats.Hits++
+		c.mu.Unlock()
+		return e, nil
+	}
+	c.stats.Misses++
+	c.mu.Unlock()
+
+	// 预编译需要访问数据库，不能持有锁
+	stmt, err := c.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// 别的 goroutine 可能已经把同一个语句放进来了
+	if elem, ok := c.items[query]; ok {
+		_ = stmt.Close()
+		c.ll.MoveToFront(elem)
+		e := elem.Value.(*stmtEntry)
+		e.refs++
+		return e, nil
+	}
+	e := &stmtEntry{query: query, stmt: stmt, refs: 1}
+	c.items[query] = c.ll.PushFront(e)
+	for c.ll.Len() > c.capacity {
+		c.evict(c.ll.Back())
+	}
+	return e, nil
+}
+
+func (c *stmtCache) release(e *stmtEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e.refs--
+	if e.evicted && e.refs == 0 {
+		_ = e.stmt.Close()
+	}
+}
+
+// evict 必须在持有锁的时候调用
+func (c *stmtCache) evict(elem *list.Element) {
+	e := c.ll.Remove(elem).(*stmtEntry)
+	delete(c.items, e.query)
+	e.evicted = true
+	c.stats.Evictions++
+	if e.refs == 0 {
+		_ = e.stmt.Close()
+	}
+}
+
+func (c *stmtCache) snapshot() StmtCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res := c.stats
+	res.Size = c.ll.Len()
+	return res
+}
+
+// close 关闭所有缓存的语句
+func (c *stmtCache) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.ll.Len() > 0 {
+		c.evict(c.ll.Back())
+	}
+}
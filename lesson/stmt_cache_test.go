@@ -0,0 +1,131 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+)
+
+func TestDB_StmtCache(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := newDB(mockDB, DBWithStmtCache(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	selectSQL := "SELECT * FROM `test_model` WHERE `id` = ?;"
+	prep := mock.ExpectPrepare(regexp.QuoteMeta(selectSQL)).WillBeClosed()
+	prep.ExpectQuery().WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	prep.ExpectQuery().WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	insertSQL := "INSERT INTO `test_model`(`id`,`first_name`,`age`,`last_name`) VALUES(?,?,?,?);"
+	mock.ExpectPrepare(regexp.QuoteMeta(insertSQL)).WillBeClosed().
+		ExpectExec().WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectClose()
+
+	// 第一次预编译，第二次命中缓存
+	tm, err := NewSelector[TestModel](db).Where(C("Id").EQ(1)).Get(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), tm.Id)
+	tm, err = NewSelector[TestModel](db).Where(C("Id").EQ(2)).Get(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), tm.Id)
+	assert.Equal(t, StmtCacheStats{Hits: 1, Misses: 1, Size: 1}, db.StmtCacheStats())
+
+	// 容量只有 1，SELECT 被淘汰并且关闭
+	_, err = NewInserter[TestModel](db).Values(&TestModel{Id: 3}).Exec(ctx).RowsAffected()
+	assert.Nil(t, err)
+	assert.Equal(t, StmtCacheStats{Hits: 1, Misses: 2, Evictions: 1, Size: 1}, db.StmtCacheStats())
+
+	assert.Nil(t, db.Close())
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestStmtCache_EvictInUse(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	c := newStmtCache(mockDB, 1)
+	ctx := context.Background()
+	mock.ExpectPrepare("SELECT 1")
+	mock.ExpectPrepare("SELECT 2")
+	mock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"1"}))
+
+	e1, err := c.get(ctx, "SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e2, err := c.get(ctx, "SELECT 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.release(e2)
+
+	// 被淘汰的语句还在使用，不能关闭
+	rows, err := e1.stmt.QueryContext(ctx)
+	assert.Nil(t, err)
+	assert.Nil(t, rows.Close())
+	// 最后一个人用完之后关闭
+	c.release(e1)
+	_, err = e1.stmt.QueryContext(ctx)
+	assert.NotNil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestTx_StmtCache_SQLite(t *testing.T) {
+	db, err := NewDB("sqlite3", "file:stmt_cache.db?cache=shared&mode=memory", DBWithStmtCache(8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	ctx := context.Background()
+	if err = db.CreateTables(ctx, &TestModel{}); err != nil {
+		t.Fatal(err)
+	}
+	before := db.StmtCacheStats()
+
+	tx, err := db.Begin(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3; i++ {
+		_, err = NewInserter[TestModel](tx).Values(&TestModel{Id: int64(i), FirstName: "Tom"}).Exec(ctx).RowsAffected()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	// 事务复用 DB 上缓存的语句
+	after := db.StmtCacheStats()
+	assert.Equal(t, uint64(2), after.Hits-before.Hits)
+	assert.Equal(t, uint64(1), after.Misses-before.Misses)
+
+	tms, err := NewSelector[TestModel](db).GetMulti(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(tms))
+}
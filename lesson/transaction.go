@@ -20,26 +20,47 @@ import (
 )
 
 type Tx struct {
+	core
 	tx *sql.Tx
-	r  *registry
+	// dirty 是事务里面修改过的表，提交之后让它们的查询缓存失效
+	dirty []string
 }
 
 func (t *Tx) Commit() error {
-	return t.tx.Commit()
+	if err := t.tx.Commit(); err != nil {
+		return err
+	}
+	for _, table := range t.dirty {
+		t.qcache.invalidate(context.Background(), table)
+	}
+	return nil
 }
 
 func (t *Tx) Rollback() error {
 	return t.tx.Rollback()
 }
 
-func (t *Tx) query(ctx context.Context, sql string, args ...any) (*sql.Rows, error) {
-	return t.tx.QueryContext(ctx, sql, args...)
+func (t *Tx) query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if t.stmts == nil {
+		return t.tx.QueryContext(ctx, query, args...)
+	}
+	e, err := t.stmts.get(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer t.stmts.release(e)
+	// 事务提交或者回滚的时候会关闭 StmtContext 返回的语句
+	return t.tx.StmtContext(ctx, e.stmt).QueryContext(ctx, args...)
 }
 
-func (t *Tx) exec(ctx context.Context, sql string, args ...any) (sql.Result, error) {
-	return t.tx.ExecContext(ctx, sql, args...)
-}
-
-func (t *Tx) registry() *registry {
-	return t.r
+func (t *Tx) exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if t.stmts == nil {
+		return t.tx.ExecContext(ctx, query, args...)
+	}
+	e, err := t.stmts.get(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer t.stmts.release(e)
+	return t.tx.StmtContext(ctx, e.stmt).ExecContext(ctx, args...)
 }
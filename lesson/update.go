@@ -0,0 +1,258 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Assignable 代表 UPDATE 语句 SET 部分的一个赋值
+// Column 表示使用 Update 传入的结构体里面对应字段的值
+// Assignment 表示直接使用给定的值
+type Assignable interface {
+	assign()
+}
+
+type Assignment struct {
+	column string
+	val    Expression
+}
+
+func (Assignment) assign() {}
+
+// Assign 例如 Assign("FirstName", "Tom")
+func Assign(column string, val any) Assignment {
+	return Assignment{
+		column: column,
+		val:    exprOf(val),
+	}
+}
+
+// ErrOptimisticLockConflict 表示更新的时候版本号已经被别人修改了，
+// 也就是 UPDATE 语句没有影响任何行
+var ErrOptimisticLockConflict = errors.New("toy-orm: 乐观锁冲突，数据已经被修改")
+
+type Updater[T any] struct {
+	builder
+	sess Session
+
+	val     *T
+	assigns []Assignable
+	where   []Predicate
+}
+
+func NewUpdater[T any](sess Session) *Updater[T] {
+	return &Updater[T]{
+		sess: sess,
+	}
+}
+
+// Update 指定更新的值，如果没有调用 Set，那么所有的字段都会被更新
+func (u *Updater[T]) Update(val *T) *Updater[T] {
+	u.val = val
+	return u
+}
+
+func (u *Updater[T]) Set(assigns ...Assignable) *Updater[T] {
+	u.assigns = assigns
+	return u
+}
+
+func (u *Updater[T]) Where(ps ...Predicate) *Updater[T] {
+	u.where = ps
+	return u
+}
+
+func (u *Updater[T]) Build() (*Query, error) {
+	return u.build(context.Background())
+}
+
+func (u *Updater[T]) build(ctx context.Context) (*Query, error) {
+	var (
+		t   T
+		err error
+	)
+	u.reset()
+	c := u.sess.getCore()
+	u.mi, err = c.r.get(&t)
+	if err != nil {
+		return nil, err
+	}
+	assigns := u.assigns
+	if len(assigns) == 0 {
+		if u.val == nil {
+			return nil, errors.New("toy-orm: 没有需要更新的列")
+		}
+		assigns = make([]Assignable, 0, len(u.mi.fields))
+		for _, fd := range u.mi.fields {
+			assigns = append(assigns, C(fd))
+		}
+		// 租户由 context 决定，不能通过更新把数据转移到别的租户
+		if tf, ok := u.mi.fieldMap[c.tenantField]; ok {
+			assigns = withoutColumn(assigns, tf.fieldName)
+		}
+	} else if tf, ok := u.mi.fieldMap[c.tenantField]; ok && assigned(assigns, tf.fieldName) && !scopesSkipped(ctx) {
+		return nil, fmt.Errorf("toy-orm: 不能更新租户字段 %s", tf.fieldName)
+	}
+	if ut := u.mi.updateTime; ut != nil {
+		now := ut.timestamp(c.clock())
+		if u.val != nil {
+			reflect.ValueOf(u.val).Elem().FieldByName(ut.fieldName).Set(reflect.ValueOf(now).Convert(ut.typ))
+		}
+		if !assigned(assigns, ut.fieldName) {
+			assigns = append(assigns[:len(assigns):len(assigns)], Assign(ut.fieldName, now))
+		}
+	}
+
+	// 版本号总是由 ORM 维护，用户设置的值会被忽略
+	ver := u.mi.version
+	if ver != nil {
+		assigns = withoutColumn(assigns, ver.fieldName)
+	}
+
+	u.sb.WriteString("UPDATE ")
+	u.quote(u.mi.tableName)
+	u.sb.WriteString(" SET ")
+	for i, a := range assigns {
+		if i > 0 {
+			u.sb.WriteByte(',')
+		}
+		if err = u.buildAssignment(a); err != nil {
+			return nil, err
+		}
+	}
+
+	scopes, err := c.scopePredicates(ctx, reflect.TypeOf(&t), u.mi)
+	if err != nil {
+		return nil, err
+	}
+	where := append(u.where[:len(u.where):len(u.where)], scopes...)
+	if ver != nil {
+		if len(assigns) > 0 {
+			u.sb.WriteByte(',')
+		}
+		u.quote(ver.columnName)
+		u.sb.WriteByte('=')
+		u.quote(ver.columnName)
+		u.sb.WriteString("+1")
+		// 只有传入了值才知道当前的版本号
+		if u.val != nil {
+			cur := reflect.ValueOf(u.val).Elem().FieldByName(ver.fieldName).Interface()
+			where = append(where, C(ver.fieldName).EQ(cur))
+		}
+	}
+
+	if err = u.buildWhere(where); err != nil {
+		return nil, err
+	}
+	u.sb.WriteByte(';')
+	return &Query{
+		SQL:  u.sb.String(),
+		Args: u.args,
+	}, nil
+}
+
+func (u *Updater[T]) buildAssignment(a Assignable) error {
+	switch assign := a.(type) {
+	case Column:
+		if u.val == nil {
+			return fmt.Errorf("toy-orm: 更新列 %s 需要先调用 Update 传入值", assign.name)
+		}
+		if err := u.buildColumn(assign.name); err != nil {
+			return err
+		}
+		u.sb.WriteByte('=')
+		u.addArg(fieldValue(reflect.ValueOf(u.val).Elem(), assign.name))
+	case Assignment:
+		if err := u.buildColumn(assign.column); err != nil {
+			return err
+		}
+		u.sb.WriteByte('=')
+		return u.buildExpression(assign.val)
+	default:
+		return fmt.Errorf("toy-orm: 不支持的赋值 %v", assign)
+	}
+	return nil
+}
+
+// assigned 判断 SET 部分是否已经包含了字段 name
+func assigned(assigns []Assignable, name string) bool {
+	for _, a := range assigns {
+		switch assign := a.(type) {
+		case Column:
+			if assign.name == name {
+				return true
+			}
+		case Assignment:
+			if assign.column == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// withoutColumn 去掉 SET 部分里面针对字段 name 的赋值
+func withoutColumn(assigns []Assignable, name string) []Assignable {
+	if !assigned(assigns, name) {
+		return assigns
+	}
+	res := make([]Assignable, 0, len(assigns)-1)
+	for _, a := range assigns {
+		if !assigned([]Assignable{a}, name) {
+			res = append(res, a)
+		}
+	}
+	return res
+}
+
+func (u *Updater[T]) Exec(ctx context.Context) sql.Result {
+	if u.val != nil {
+		if err := beforeUpdate(ctx, u.sess, u.val); err != nil {
+			return Result{err: err}
+		}
+	}
+	q, err := u.build(ctx)
+	if err != nil {
+		return Result{err: err}
+	}
+	res, err := u.sess.exec(ctx, q.SQL, q.Args...)
+	invalidateCache(ctx, u.sess, u.mi.tableName)
+	if err != nil {
+		return Result{err: err}
+	}
+	if ver := u.mi.version; ver != nil && u.val != nil {
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return Result{err: err, res: res}
+		}
+		if affected == 0 {
+			return Result{err: ErrOptimisticLockConflict, res: res}
+		}
+		fd := reflect.ValueOf(u.val).Elem().FieldByName(ver.fieldName)
+		if fd.CanInt() {
+			fd.SetInt(fd.Int() + 1)
+		} else {
+			fd.SetUint(fd.Uint() + 1)
+		}
+	}
+	return Result{
+		res: res,
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lesson
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestUpdater_Build(t *testing.T) {
+	mockDB, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tm := &TestModel{
+		Id:        12,
+		FirstName: "Tom",
+		Age:       18,
+		LastName:  &sql.NullString{String: "Jerry", Valid: true},
+	}
+	testCases := []struct {
+		name     string
+		q        QueryBuilder
+		wantSQL  string
+		wantArgs []any
+		wantErr  error
+	}{
+		{
+			// 既没有 Update 也没有 Set
+			name:    "no columns",
+			q:       NewUpdater[TestModel](db),
+			wantErr: errors.New("toy-orm: 没有需要更新的列"),
+		},
+		{
+			// 更新全部列
+			name:     "all columns",
+			q:        NewUpdater[TestModel](db).Update(tm),
+			wantSQL:  "UPDATE `test_model` SET `id`=?,`first_name`=?,`age`=?,`last_name`=?;",
+			wantArgs: []any{int64(12), "Tom", int8(18), &sql.NullString{String: "Jerry", Valid: true}},
+		},
+		{
+			// 指定列
+			name: "specify columns",
+			q: NewUpdater[TestModel](db).Update(tm).
+				Set(C("FirstName"), C("Age")).Where(C("Id").EQ(12)),
+			wantSQL:  "UPDATE `test_model` SET `first_name`=?,`age`=? WHERE `id` = ?;",
+			wantArgs: []any{"Tom", int8(18), 12},
+		},
+		{
+			// 直接赋值
+			name: "assignment",
+			q: NewUpdater[TestModel](db).
+				Set(Assign("FirstName", "Jerry"), Assign("Age", 20)).Where(C("Id").EQ(12)),
+			wantSQL:  "UPDATE `test_model` SET `first_name`=?,`age`=? WHERE `id` = ?;",
+			wantArgs: []any{"Jerry", 20, 12},
+		},
+		{
+			// 使用 Column 但是没有传入值
+			name:    "column without value",
+			q:       NewUpdater[TestModel](db).Set(C("FirstName")),
+			wantErr: errors.New("toy-orm: 更新列 FirstName 需要先调用 Update 传入值"),
+		},
+		{
+			// 非法列名
+			name:    "invalid column",
+			q:       NewUpdater[TestModel](db).Set(Assign("Invalid", 1)),
+			wantErr: errors.New("toy-orm: 非法列名 Invalid"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantSQL, q.SQL)
+			assert.Equal(t, tc.wantArgs, q.Args)
+		})
+	}
+}
+
+func TestUpdater_Exec(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectExec("UPDATE .*").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	res := NewUpdater[TestModel](db).Set(Assign("FirstName", "Tom")).
+		Where(C("Id").EQ(1)).Exec(context.Background())
+	affected, err := res.RowsAffected()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(1), affected)
+}
+
+func TestUpdater_Timestamp(t *testing.T) {
+	type Order struct {
+		Id         int64
+		Status     string
+		UpdateTime int64 `orm:"updateTime=second"`
+	}
+	now := time.Unix(1655000000, 0)
+	mockDB, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB, DBWithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name     string
+		q        QueryBuilder
+		wantSQL  string
+		wantArgs []any
+	}{
+		{
+			name:     "all columns",
+			q:        NewUpdater[Order](db).Update(&Order{Id: 1, Status: "paid", UpdateTime: 123}),
+			wantSQL:  "UPDATE `order` SET `id`=?,`status`=?,`update_time`=?;",
+			wantArgs: []any{int64(1), "paid", int64(1655000000)},
+		},
+		{
+			name:     "append update time",
+			q:        NewUpdater[Order](db).Set(Assign("Status", "paid")).Where(C("Id").EQ(1)),
+			wantSQL:  "UPDATE `order` SET `status`=?,`update_time`=? WHERE `id` = ?;",
+			wantArgs: []any{"paid", int64(1655000000), 1},
+		},
+		{
+			// 用户显式赋值
+			name:     "explicit update time",
+			q:        NewUpdater[Order](db).Set(Assign("Status", "paid"), Assign("UpdateTime", 1)),
+			wantSQL:  "UPDATE `order` SET `status`=?,`update_time`=?;",
+			wantArgs: []any{"paid", 1},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.q.Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.wantSQL, q.SQL)
+			assert.Equal(t, tc.wantArgs, q.Args)
+		})
+	}
+
+	// int64 的自定义类型，更新时间会写回 val
+	type MillisOrder struct {
+		Id         int64
+		UpdateTime Millis
+	}
+	mo := &MillisOrder{Id: 1}
+	q, err := NewUpdater[MillisOrder](db).Update(mo).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "UPDATE `millis_order` SET `id`=?,`update_time`=?;", q.SQL)
+	assert.Equal(t, []any{int64(1), Millis(1655000000000)}, q.Args)
+	assert.Equal(t, Millis(1655000000000), mo.UpdateTime)
+}
+
+type VersionModel struct {
+	Id        int64
+	FirstName string
+	Version   int64 `orm:"version"`
+}
+
+func TestUpdater_Version(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := newDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name     string
+		q        QueryBuilder
+		wantSQL  string
+		wantArgs []any
+	}{
+		{
+			// 用户设置的版本号会被忽略
+			name:     "all columns",
+			q:        NewUpdater[VersionModel](db).Update(&VersionModel{Id: 1, FirstName: "Tom", Version: 3}),
+			wantSQL:  "UPDATE `version_model` SET `id`=?,`first_name`=?,`version`=`version`+1 WHERE `version` = ?;",
+			wantArgs: []any{int64(1), "Tom", int64(3)},
+		},
+		{
+			name: "with where",
+			q: NewUpdater[VersionModel](db).Update(&VersionModel{FirstName: "Tom", Version: 3}).
+				Set(C("FirstName")).Where(C("Id").EQ(1)),
+			wantSQL:  "UPDATE `version_model` SET `first_name`=?,`version`=`version`+1 WHERE (`id` = ?) AND (`version` = ?);",
+			wantArgs: []any{"Tom", 1, int64(3)},
+		},
+		{
+			// 没有传入值，只能递增版本号
+			name:     "without value",
+			q:        NewUpdater[VersionModel](db).Set(Assign("FirstName", "Tom")).Where(C("Id").EQ(1)),
+			wantSQL:  "UPDATE `version_model` SET `first_name`=?,`version`=`version`+1 WHERE `id` = ?;",
+			wantArgs: []any{"Tom", 1},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.q.Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.wantSQL, q.SQL)
+			assert.Equal(t, tc.wantArgs, q.Args)
+		})
+	}
+
+	// 更新成功，版本号递增
+	mock.ExpectExec("UPDATE `version_model`").WillReturnResult(sqlmock.NewResult(0, 1))
+	vm := &VersionModel{Id: 1, FirstName: "Tom", Version: 3}
+	_, err = NewUpdater[VersionModel](db).Update(vm).Set(C("FirstName")).
+		Where(C("Id").EQ(1)).Exec(context.Background()).RowsAffected()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), vm.Version)
+
+	// 没有影响任何行，说明版本号已经被别人修改了
+	mock.ExpectExec("UPDATE `version_model`").WillReturnResult(sqlmock.NewResult(0, 0))
+	_, err = NewUpdater[VersionModel](db).Update(vm).Set(C("FirstName")).
+		Where(C("Id").EQ(1)).Exec(context.Background()).RowsAffected()
+	assert.Equal(t, ErrOptimisticLockConflict, err)
+	assert.Equal(t, int64(4), vm.Version)
+}
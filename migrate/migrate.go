@@ -0,0 +1,245 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package migrate 按照版本号执行数据库迁移。
+// 迁移可以是 SQL 脚本，也可以是 Go 代码，已经执行过的版本记录在 schema_migrations 表里面。
+// 每一个迁移都在单独的事务里面执行，失败的时候只会回滚当前的迁移。
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Migration 是一个版本的迁移，Down 为 nil 的时候不能回滚
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(ctx context.Context, tx *sql.Tx) error
+	Down    func(ctx context.Context, tx *sql.Tx) error
+}
+
+// Status 是一个迁移的执行状态
+type Status struct {
+	Version int64
+	Name    string
+	Applied bool
+	// AppliedAt 是执行的时间，没有执行的时候是零值
+	AppliedAt time.Time
+}
+
+type Option func(m *Migrator)
+
+// WithTable 指定记录迁移版本的表，默认是 schema_migrations
+func WithTable(table string) Option {
+	return func(m *Migrator) {
+		m.table = table
+	}
+}
+
+type Migrator struct {
+	db    *sql.DB
+	table string
+	// migrations 按照版本号从小到大排列
+	migrations []*Migration
+}
+
+func New(db *sql.DB, opts ...Option) *Migrator {
+	m := &Migrator{
+		db:    db,
+		table: "schema_migrations",
+	}
+	for _, o := range opts {
+		o(m)
+	}
+	return m
+}
+
+// Add 添加 Go 代码实现的迁移，版本号不能和已有的迁移重复
+func (m *Migrator) Add(migrations ...*Migration) error {
+	for _, mg := range migrations {
+		if mg.Version <= 0 || mg.Up == nil {
+			return fmt.Errorf("toy-orm: 迁移 %d_%s 的版本号必须大于 0 并且有 Up", mg.Version, mg.Name)
+		}
+		if m.find(mg.Version) != nil {
+			return fmt.Errorf("toy-orm: 迁移版本 %d 重复", mg.Version)
+		}
+		m.migrations = append(m.migrations, mg)
+	}
+	sort.Slice(m.migrations, func(i, j int) bool {
+		return m.migrations[i].Version < m.migrations[j].Version
+	})
+	return nil
+}
+
+// Up 执行所有还没有执行的迁移
+func (m *Migrator) Up(ctx context.Context) error {
+	if len(m.migrations) == 0 {
+		return nil
+	}
+	return m.Goto(ctx, m.migrations[len(m.migrations)-1].Version)
+}
+
+// Down 回滚最近执行的一个迁移，没有执行过任何迁移的时候什么也不做
+func (m *Migrator) Down(ctx context.Context) error {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	var latest int64
+	for v := range applied {
+		if v > latest {
+			latest = v
+		}
+	}
+	if latest == 0 {
+		return nil
+	}
+	mg := m.find(latest)
+	if mg == nil {
+		return fmt.Errorf("toy-orm: 找不到已经执行的迁移 %d", latest)
+	}
+	return m.run(ctx, mg, false)
+}
+
+// Goto 迁移到 version：执行不大于 version 并且还没有执行的迁移，
+// 然后从大到小回滚大于 version 的迁移。version 为 0 的时候回滚所有的迁移
+func (m *Migrator) Goto(ctx context.Context, version int64) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("toy-orm: 迁移版本 %d 不存在", version)
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	for v := range applied {
+		if v > version && m.find(v) == nil {
+			return fmt.Errorf("toy-orm: 找不到已经执行的迁移 %d", v)
+		}
+	}
+	for _, mg := range m.migrations {
+		if _, ok := applied[mg.Version]; !ok && mg.Version <= version {
+			if err = m.run(ctx, mg, true); err != nil {
+				return err
+			}
+		}
+	}
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mg := m.migrations[i]
+		if _, ok := applied[mg.Version]; ok && mg.Version > version {
+			if err = m.run(ctx, mg, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Status 返回所有迁移的执行状态，按照版本号从小到大排列。
+// 已经执行但是没有加载的迁移也会出现在结果里面
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Status, 0, len(m.migrations))
+	for _, mg := range m.migrations {
+		st := Status{Version: mg.Version, Name: mg.Name}
+		if a, ok := applied[mg.Version]; ok {
+			st.Applied, st.AppliedAt = true, a.AppliedAt
+			delete(applied, mg.Version)
+		}
+		res = append(res, st)
+	}
+	for _, a := range applied {
+		res = append(res, a)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Version < res[j].Version
+	})
+	return res, nil
+}
+
+// run 在事务里面执行一个迁移，并且更新迁移记录
+func (m *Migrator) run(ctx context.Context, mg *Migration, up bool) (err error) {
+	fn, direction := mg.Up, "up"
+	if !up {
+		fn, direction = mg.Down, "down"
+	}
+	if fn == nil {
+		return fmt.Errorf("toy-orm: 迁移 %d_%s 不能回滚", mg.Version, mg.Name)
+	}
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			err = fmt.Errorf("toy-orm: 迁移 %d_%s %s 失败: %w", mg.Version, mg.Name, direction, err)
+		}
+	}()
+	if err = fn(ctx, tx); err != nil {
+		return err
+	}
+	if up {
+		_, err = tx.ExecContext(ctx, "INSERT INTO `"+m.table+"`(`version`,`name`,`applied_at`) VALUES(?,?,?);",
+			mg.Version, mg.Name, time.Now().UnixMilli())
+	} else {
+		_, err = tx.ExecContext(ctx, "DELETE FROM `"+m.table+"` WHERE `version` = ?;", mg.Version)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// applied 返回已经执行的迁移，key 是版本号。记录迁移的表不存在的时候会先创建
+func (m *Migrator) applied(ctx context.Context) (map[int64]Status, error) {
+	_, err := m.db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS `"+m.table+"`("+
+		"`version` BIGINT NOT NULL PRIMARY KEY, `name` VARCHAR(255) NOT NULL, `applied_at` BIGINT NOT NULL);")
+	if err != nil {
+		return nil, err
+	}
+	rows, err := m.db.QueryContext(ctx, "SELECT `version`,`name`,`applied_at` FROM `"+m.table+"`;")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	res := make(map[int64]Status, len(m.migrations))
+	for rows.Next() {
+		var (
+			st        = Status{Applied: true}
+			appliedAt int64
+		)
+		if err = rows.Scan(&st.Version, &st.Name, &appliedAt); err != nil {
+			return nil, err
+		}
+		st.AppliedAt = time.UnixMilli(appliedAt)
+		res[st.Version] = st
+	}
+	return res, rows.Err()
+}
+
+func (m *Migrator) find(version int64) *Migration {
+	for _, mg := range m.migrations {
+		if mg.Version == version {
+			return mg
+		}
+	}
+	return nil
+}
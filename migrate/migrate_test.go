@@ -0,0 +1,195 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testing/fstest"
+)
+
+var testFS = fstest.MapFS{
+	"0001_create_user.up.sql":   {Data: []byte("CREATE TABLE `user`(`id` INTEGER PRIMARY KEY, `name` TEXT);")},
+	"0001_create_user.down.sql": {Data: []byte("DROP TABLE `user`;")},
+	"0002_add_age.up.sql":       {Data: []byte("ALTER TABLE `user` ADD COLUMN `age` INTEGER;")},
+	"0002_add_age.down.sql":     {Data: []byte("ALTER TABLE `user` DROP COLUMN `age`;")},
+	"README.md":                 {Data: []byte("不是迁移脚本")},
+}
+
+func newTestMigrator(t *testing.T, name string) (*Migrator, *sql.DB) {
+	db, err := sql.Open("sqlite3", "file:"+name+"?cache=shared&mode=memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	m := New(db)
+	if err = m.Load(testFS); err != nil {
+		t.Fatal(err)
+	}
+	// Go 代码实现的迁移
+	err = m.Add(&Migration{
+		Version: 3,
+		Name:    "seed_user",
+		Up: func(ctx context.Context, tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO `user`(`id`,`name`,`age`) VALUES(1,'Tom',18);")
+			return err
+		},
+		Down: func(ctx context.Context, tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, "DELETE FROM `user` WHERE `id` = 1;")
+			return err
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, db
+}
+
+// appliedVersions 返回已经执行的版本号
+func appliedVersions(t *testing.T, m *Migrator) []int64 {
+	sts, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res []int64
+	for _, st := range sts {
+		if st.Applied {
+			res = append(res, st.Version)
+		}
+	}
+	return res
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestMigrator(t, "migrate_updown.db")
+
+	sts, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []Status{
+		{Version: 1, Name: "create_user"},
+		{Version: 2, Name: "add_age"},
+		{Version: 3, Name: "seed_user"},
+	}, sts)
+
+	if err = m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []int64{1, 2, 3}, appliedVersions(t, m))
+	var age int
+	err = db.QueryRowContext(ctx, "SELECT `age` FROM `user` WHERE `id` = 1;").Scan(&age)
+	assert.Nil(t, err)
+	assert.Equal(t, 18, age)
+
+	// 重复执行 Up 不会有任何效果
+	assert.Nil(t, m.Up(ctx))
+
+	assert.Nil(t, m.Down(ctx))
+	assert.Equal(t, []int64{1, 2}, appliedVersions(t, m))
+
+	assert.Nil(t, m.Goto(ctx, 1))
+	assert.Equal(t, []int64{1}, appliedVersions(t, m))
+	_, err = db.ExecContext(ctx, "SELECT `age` FROM `user`;")
+	assert.NotNil(t, err)
+
+	assert.Nil(t, m.Goto(ctx, 3))
+	assert.Equal(t, []int64{1, 2, 3}, appliedVersions(t, m))
+
+	assert.Nil(t, m.Goto(ctx, 0))
+	assert.Nil(t, appliedVersions(t, m))
+
+	assert.Equal(t, errors.New("toy-orm: 迁移版本 4 不存在"), m.Goto(ctx, 4))
+}
+
+func TestMigrator_Failed(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestMigrator(t, "migrate_failed.db")
+	err := m.Add(&Migration{
+		Version: 4,
+		Name:    "broken",
+		Up: func(ctx context.Context, tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO `user`(`id`,`name`) VALUES(2,'Jerry');"); err != nil {
+				return err
+			}
+			return errors.New("mock error")
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.Up(ctx)
+	assert.Equal(t, "toy-orm: 迁移 4_broken up 失败: mock error", err.Error())
+	// 失败的迁移被回滚，之前的迁移不受影响
+	assert.Equal(t, []int64{1, 2, 3}, appliedVersions(t, m))
+	var cnt int
+	err = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM `user`;").Scan(&cnt)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, cnt)
+}
+
+func TestMigrator_Load(t *testing.T) {
+	testCases := []struct {
+		name    string
+		fsys    fstest.MapFS
+		wantErr error
+	}{
+		{
+			name: "duplicate version",
+			fsys: fstest.MapFS{
+				"0001_a.up.sql": {Data: []byte("")},
+				"0001_b.up.sql": {Data: []byte("")},
+			},
+			wantErr: errors.New("toy-orm: 迁移版本 1 重复，a 和 b"),
+		},
+		{
+			// 只有 down 脚本
+			name: "missing up",
+			fsys: fstest.MapFS{
+				"0001_a.down.sql": {Data: []byte("")},
+			},
+			wantErr: errors.New("toy-orm: 迁移 1_a 缺少 up 脚本"),
+		},
+		{
+			name: "ignore invalid name",
+			fsys: fstest.MapFS{
+				"a_b.up.sql":      {Data: []byte("")},
+				"0001_a.up.sql":   {Data: []byte("")},
+				"0001_a.down.txt": {Data: []byte("")},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := New(nil)
+			err := m.Load(tc.fsys)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func TestMigrator_Add(t *testing.T) {
+	m := New(nil)
+	noop := func(ctx context.Context, tx *sql.Tx) error { return nil }
+	assert.Nil(t, m.Add(&Migration{Version: 1, Name: "a", Up: noop}))
+	assert.Equal(t, errors.New("toy-orm: 迁移版本 1 重复"), m.Add(&Migration{Version: 1, Name: "b", Up: noop}))
+	assert.Equal(t, errors.New("toy-orm: 迁移 0_c 的版本号必须大于 0 并且有 Up"), m.Add(&Migration{Name: "c", Up: noop}))
+}
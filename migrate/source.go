@@ -0,0 +1,97 @@
+// Copyright 2021 gotomicro
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// Load 从 fsys 的根目录加载 SQL 迁移脚本。
+// 文件名的格式是 <版本号>_<名字>.up.sql 和 <版本号>_<名字>.down.sql，
+// 例如 0001_create_user.up.sql。down 脚本可以没有，但是这样就不能回滚。
+// 一个脚本里面有多条语句的时候，MySQL 需要在 DSN 里面加上 multiStatements=true
+func (m *Migrator) Load(fsys fs.FS) error {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return err
+	}
+	loaded := make(map[int64]*Migration, len(entries)/2)
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		version, name, up, ok := parseFileName(e.Name())
+		if !ok {
+			continue
+		}
+		script, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return err
+		}
+		mg, has := loaded[version]
+		if !has {
+			mg = &Migration{Version: version, Name: name}
+			loaded[version] = mg
+		} else if mg.Name != name {
+			return fmt.Errorf("toy-orm: 迁移版本 %d 重复，%s 和 %s", version, mg.Name, name)
+		}
+		if up {
+			mg.Up = execScript(string(script))
+		} else {
+			mg.Down = execScript(string(script))
+		}
+	}
+	res := make([]*Migration, 0, len(loaded))
+	for _, mg := range loaded {
+		if mg.Up == nil {
+			return fmt.Errorf("toy-orm: 迁移 %d_%s 缺少 up 脚本", mg.Version, mg.Name)
+		}
+		res = append(res, mg)
+	}
+	return m.Add(res...)
+}
+
+// parseFileName 解析迁移脚本的文件名，不是迁移脚本的时候 ok 为 false
+func parseFileName(fn string) (version int64, name string, up bool, ok bool) {
+	switch {
+	case strings.HasSuffix(fn, ".up.sql"):
+		fn, up = strings.TrimSuffix(fn, ".up.sql"), true
+	case strings.HasSuffix(fn, ".down.sql"):
+		fn = strings.TrimSuffix(fn, ".down.sql")
+	default:
+		return 0, "", false, false
+	}
+	v, name, _ := strings.Cut(fn, "_")
+	version, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, "", false, false
+	}
+	return version, name, up, true
+}
+
+func execScript(script string) func(ctx context.Context, tx *sql.Tx) error {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		if strings.TrimSpace(script) == "" {
+			return nil
+		}
+		_, err := tx.ExecContext(ctx, script)
+		return err
+	}
+}